@@ -0,0 +1,142 @@
+package _mysql
+
+import (
+	"github.com/and-hom/csv2db/common"
+	"github.com/go-sql-driver/mysql"
+	"github.com/sirupsen/logrus"
+	"io"
+	"bufio"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"errors"
+)
+
+const LOAD_DATA_BUFFER_SIZE = 64 * 1024
+
+// LOAD DATA defaults: tab separated fields, backslash escaping, \N for NULL
+var loadDataFormat = common.TextRowFormat{True:"1", False:"0"}
+
+var readerCounter int64 = 0
+
+var errAborted = errors.New("Load aborted")
+
+// Streams rows into LOAD DATA LOCAL INFILE 'Reader::name' statement executed in background.
+// Statement runs in transaction which is committed only if all rows are loaded.
+type loadDataInserter struct {
+	tx           common.Tx
+	insertSchema common.InsertSchema
+	readerName   string
+	pipe         *io.PipeWriter
+	out          *bufio.Writer
+	rows         int64
+	done         chan loadDataResult
+}
+
+type loadDataResult struct {
+	rowsAffected int64
+	err          error
+}
+
+func loadDataQuery(dbTool common.DbTool, readerName string, tableName common.TableName, insertSchema common.InsertSchema) (string, error) {
+	if len(insertSchema.OrderedDbColumns) == 0 {
+		return "", fmt.Errorf("Can not insert 0 columns")
+	}
+	escapedNames := make([]string, len(insertSchema.OrderedDbColumns))
+	for i, name := range insertSchema.OrderedDbColumns {
+		escapedNames[i] = dbTool.Escape(name)
+	}
+	return fmt.Sprintf(`LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE %s CHARACTER SET utf8mb4
+		FIELDS TERMINATED BY '\t' ENCLOSED BY '' ESCAPED BY '\\'
+		LINES TERMINATED BY '\n' (%s)`, readerName, tableName.String(), strings.Join(escapedNames, ",")), nil
+}
+
+func CreateLoadDataInserter(db common.Db, dbTool common.DbTool, tableName common.TableName, insertSchema common.InsertSchema) (common.Inserter, error) {
+	readerName := fmt.Sprintf("csv2db-%d", atomic.AddInt64(&readerCounter, 1))
+	query, err := loadDataQuery(dbTool, readerName, tableName, insertSchema)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Debug("Query is " + query)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	pipeReader, pipeWriter := io.Pipe()
+	mysql.RegisterReaderHandler(readerName, func() io.Reader {
+		return pipeReader
+	})
+
+	ins := loadDataInserter{
+		tx:tx,
+		insertSchema:insertSchema,
+		readerName:readerName,
+		pipe:pipeWriter,
+		out:bufio.NewWriterSize(pipeWriter, LOAD_DATA_BUFFER_SIZE),
+		done:make(chan loadDataResult, 1),
+	}
+	go func() {
+		result := loadDataResult{}
+		res, err := tx.Exec(query)
+		if err == nil {
+			result.rowsAffected, err = res.RowsAffected()
+		}
+		result.err = err
+		// unblock writer if statement failed before reading all rows
+		pipeReader.CloseWithError(err)
+		ins.done <- result
+	}()
+	return &ins, nil
+}
+
+func (this *loadDataInserter) Add(values ...interface{}) error {
+	if _, err := this.out.WriteString(loadDataFormat.Format(values)); err != nil {
+		return err
+	}
+	this.rows += 1
+	return nil
+}
+
+func (this *loadDataInserter) Close() error {
+	defer mysql.DeregisterReaderHandler(this.readerName)
+
+	err := this.out.Flush()
+	this.pipe.Close()
+	result := <-this.done
+	if result.err != nil {
+		this.tx.Rollback()
+		return result.err
+	}
+	if err != nil {
+		this.tx.Rollback()
+		return err
+	}
+	// LOCAL data load turns errors like duplicate keys into warnings and skips rows
+	if result.rowsAffected < this.rows {
+		this.tx.Rollback()
+		return fmt.Errorf("%d of %d rows were skipped by LOAD DATA because of duplicate keys or invalid values",
+			this.rows - result.rowsAffected, this.rows)
+	}
+	return this.tx.Commit()
+}
+
+// Failed reader makes LOAD DATA statement fail, rows loaded before are discarded by transaction rollback
+func (this *loadDataInserter) Abort() error {
+	defer mysql.DeregisterReaderHandler(this.readerName)
+
+	this.pipe.CloseWithError(errAborted)
+	// statement fails with errAborted
+	<-this.done
+	return this.tx.Rollback()
+}
+
+// LOAD DATA LOCAL requires local_infile server variable to be ON
+func LocalInfileEnabled(db common.Db) bool {
+	enabled := false
+	if err := db.QueryRow("SELECT @@local_infile").Scan(&enabled); err != nil {
+		logrus.Warnf("Can not check local_infile variable: %v", err)
+		return false
+	}
+	return enabled
+}
@@ -7,23 +7,31 @@ import (
 	"reflect"
 	"github.com/sirupsen/logrus"
 	_ "github.com/go-sql-driver/mysql"
-	"bytes"
-	"strings"
 	"log"
+	"strings"
+	"fmt"
 	"github.com/and-hom/csv2db/common/inserter"
 )
 
-func MakeDbTool(db *sql.DB) common.DbTool {
-	rows, err := db.Query("SELECT DATABASE()")
-	if err != nil || !rows.Next() {
-		log.Fatalf("Can not determine current schema: %v", err)
-	}
+func MakeDbTool(db common.Db, insertMethod string) common.DbTool {
+	// rows should be read completely because connection may be shared by all queries of rollback mode
 	defaultSchema := ""
-	err = rows.Scan(&defaultSchema)
+	err := db.QueryRow("SELECT DATABASE()").Scan(&defaultSchema)
 	if err != nil {
 		log.Fatalf("Can not determine current schema: %v", err)
 	}
-	tool := myDbTool{common.CommonDbTool{
+	tool := makeDbTool(db, defaultSchema)
+	tool.insertMethod = insertMethod
+	return tool
+}
+
+// Tool to generate queries without database connection. Empty default schema means unqualified table names.
+func MakeDialect(defaultSchema string) common.DbTool {
+	return makeDbTool(nil, defaultSchema)
+}
+
+func makeDbTool(db common.Db, defaultSchema string) myDbTool {
+	tool := myDbTool{CommonDbTool:common.CommonDbTool{
 		Db:db,
 		DbToGoTypeMapping:make(map[string]reflect.Kind),
 		GoTypeToDbMapping:make(map[reflect.Kind]string),
@@ -31,6 +39,7 @@ func MakeDbTool(db *sql.DB) common.DbTool {
 		EscapeF:func(s string) string {
 			return "`" + s + "`"
 		},
+		QuoteF:quote,
 	}, }
 	tool.RegisterType(reflect.Int64, "bigint")
 	tool.RegisterType(reflect.Int32, "int", "mediumint")
@@ -39,14 +48,37 @@ func MakeDbTool(db *sql.DB) common.DbTool {
 
 	tool.RegisterType(reflect.Float64, "double", "double precision")
 	tool.RegisterType(reflect.Float32, "float", "real")
+	tool.RegisterType(common.Decimal, "decimal", "numeric")
+	// mysql decimal without precision is decimal(10,0)
+	tool.GoTypeToDbMapping[common.Decimal] = "decimal(65,30)"
+
+	tool.RegisterType(reflect.Bool, "boolean")
 
-	tool.RegisterType(reflect.String, "text", "varchar", "char", "json", "enum", "date", "time", "timestamp", )
+	tool.RegisterType(reflect.String, "text", "varchar", "char", "enum", )
+	tool.RegisterType(common.Date, "date")
+	tool.RegisterType(common.Time, "time")
+	tool.RegisterType(common.Timestamp, "datetime")
+	tool.RegisterType(common.TimestampTz, "timestamp")
+	tool.RegisterType(common.Json, "json")
+	tool.UpsertClauseF = tool.onDuplicateKeyClause
+	tool.IdentityType = "bigint AUTO_INCREMENT"
 
 	return tool
 }
 
+// Length of string key columns
+const MAX_KEY_LENGTH = 255
+
 type myDbTool struct {
 	common.CommonDbTool
+	insertMethod string
+}
+
+// Backslash is an escape character in MySQL string literals by default
+var quoteReplacer = strings.NewReplacer(`\`, `\\`, "'", "''", "\x00", `\0`)
+
+func quote(s string) string {
+	return "'" + quoteReplacer.Replace(s) + "'"
 }
 
 func (this myDbTool) Exists(tableName common.TableName) (bool, error) {
@@ -70,7 +102,7 @@ func (this myDbTool) Exists(tableName common.TableName) (bool, error) {
 }
 
 func (this myDbTool) LoadSchema(tableName common.TableName) (common.Schema, error) {
-	rows, err := this.Db.Query(`SELECT COLUMN_NAME, IS_NULLABLE, DATA_TYPE
+	rows, err := this.Db.Query(`SELECT COLUMN_NAME, IS_NULLABLE, DATA_TYPE, NUMERIC_PRECISION, NUMERIC_SCALE, CHARACTER_MAXIMUM_LENGTH
   					FROM INFORMATION_SCHEMA.COLUMNS
   					WHERE table_schema = ?
   					AND table_name = ?
@@ -86,11 +118,14 @@ func (this myDbTool) LoadSchema(tableName common.TableName) (common.Schema, erro
 		colName := ""
 		dataType := ""
 		nullableStr := ""
+		precision := sql.NullInt64{}
+		scale := sql.NullInt64{}
+		length := sql.NullInt64{}
 		colDef := common.ColDef{OrderIndex:i}
 		typeOk := false
 		i += 1
 
-		err := rows.Scan(&colName, &nullableStr, &dataType)
+		err := rows.Scan(&colName, &nullableStr, &dataType, &precision, &scale, &length)
 		if err != nil {
 			return common.Schema{}, err
 		}
@@ -101,46 +136,184 @@ func (this myDbTool) LoadSchema(tableName common.TableName) (common.Schema, erro
 			logrus.Warnf("Can not detect go type for column type %s - skip column", dataType)
 			continue
 		}
+		if colDef.GoType == common.Decimal {
+			colDef.Precision = int(precision.Int64)
+			colDef.Scale = int(scale.Int64)
+		} else if dataType == "varchar" || dataType == "char" {
+			colDef.Length = int(length.Int64)
+		}
 		schema.Add(colName, colDef)
 	}
 	return schema, nil
 }
 
-func (this myDbTool) InsertQuery(tableName common.TableName, insertSchema common.InsertSchema) (string, error) {
-	return this.InsertQueryMultiple(tableName, insertSchema, 1)
+func (this myDbTool) PrimaryKey(tableName common.TableName) ([]string, error) {
+	return this.QueryStrings(`SELECT COLUMN_NAME
+					FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE
+					WHERE TABLE_SCHEMA = ?
+					AND TABLE_NAME = ?
+					AND CONSTRAINT_NAME = 'PRIMARY'
+					ORDER BY ORDINAL_POSITION ASC`, tableName.SchemaPlain, tableName.TablePlain)
+}
+
+// MySQL resolves conflicts of any unique key. Skip policy updates the first key column by itself.
+func (this myDbTool) onDuplicateKeyClause(insertSchema common.InsertSchema) string {
+	updateColumns := insertSchema.Upsert.ColumnsToUpdate(insertSchema)
+	if len(updateColumns) == 0 {
+		key := this.Escape(insertSchema.Upsert.KeyColumns[0])
+		return fmt.Sprintf(" ON DUPLICATE KEY UPDATE %s = %s", key, key)
+	}
+	assignments := make([]string, len(updateColumns))
+	for i, name := range updateColumns {
+		assignments[i] = fmt.Sprintf("%s = VALUES(%s)", this.Escape(name), this.Escape(name))
+	}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(assignments, ", ")
+}
+
+// MySQL has no UPDATE ... FROM and IS DISTINCT FROM - use UPDATE with JOIN and null-safe <=> comparison
+func (this myDbTool) SyncQueries(target, staging common.TableName, sync common.Sync) common.SyncQueries {
+	queries := this.CommonDbTool.SyncQueries(target, staging, sync)
+	assignments := make([]string, 0, len(sync.Columns) + len(sync.SetColumns) + 1)
+	changes := make([]string, 0, len(sync.Columns) + 1)
+	for _, name := range sync.Columns {
+		assignments = append(assignments, fmt.Sprintf("t.%s = s.%s", this.Escape(name), this.Escape(name)))
+		changes = append(changes, fmt.Sprintf("NOT (t.%s <=> s.%s)", this.Escape(name), this.Escape(name)))
+	}
+	for _, name := range sync.SetColumns {
+		assignments = append(assignments, fmt.Sprintf("t.%s = s.%s", this.Escape(name), this.Escape(name)))
+	}
+	if sync.SoftDeleteColumn != "" {
+		assignments = append(assignments, fmt.Sprintf("t.%s = %s", this.Escape(sync.SoftDeleteColumn), this.Literal(false)))
+		changes = append(changes, this.NullOrNotEqual("t." + this.Escape(sync.SoftDeleteColumn), false))
+	}
+	if len(changes) > 0 {
+		queries.Update = fmt.Sprintf("UPDATE %s AS t JOIN %s AS s ON %s SET %s WHERE %s",
+			target.String(), staging.String(), this.KeysEqual("t", "s", sync.KeyColumns),
+			strings.Join(assignments, ", "), strings.Join(changes, " OR "))
+	}
+	return queries
+}
+
+func (this myDbTool) AlterColumnType(tableName common.TableName, name string, colDef common.ColDef) error {
+	query, err := this.AlterColumnTypeQuery(tableName, name, colDef)
+	if err != nil {
+		return err
+	}
+	logrus.Debug(query)
+	_, err = this.Db.Exec(query)
+	return err
+}
+
+// MODIFY COLUMN replaces whole column definition including nullability, so default value, auto increment,
+// comment and collation of existing column are taken from catalog and repeated
+func (this myDbTool) AlterColumnTypeQuery(tableName common.TableName, name string, colDef common.ColDef) (string, error) {
+	columnDefinition, err := this.ColumnDefinition(name, colDef)
+	if err != nil {
+		return "", err
+	}
+	if this.Db != nil {
+		attributes, err := this.columnAttributes(tableName, name)
+		if err != nil {
+			return "", fmt.Errorf("Can not get attributes of column %s: %v", name, err)
+		}
+		if strings.Contains(attributes.extra, "GENERATED") && !strings.Contains(attributes.extra, "DEFAULT_GENERATED") {
+			return "", fmt.Errorf("Generated column %s can not be changed", name)
+		}
+		columnDefinition += attributes.definition(colDef)
+	}
+	return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s", tableName.String(), columnDefinition), nil
+}
+
+// Parts of column definition besides type and nullability
+type columnAttributes struct {
+	defaultValue sql.NullString
+	extra        string
+	comment      string
+	collation    sql.NullString
+}
+
+func (this myDbTool) columnAttributes(tableName common.TableName, name string) (columnAttributes, error) {
+	attributes := columnAttributes{}
+	err := this.Db.QueryRow(`SELECT COLUMN_DEFAULT, EXTRA, COLUMN_COMMENT, COLLATION_NAME
+  					FROM INFORMATION_SCHEMA.COLUMNS
+  					WHERE table_schema = ?
+  					AND table_name = ?
+  					AND column_name = ?`, tableName.SchemaPlain, tableName.TablePlain, name).Scan(
+		&attributes.defaultValue, &attributes.extra, &attributes.comment, &attributes.collation)
+	return attributes, err
 }
 
-func (this myDbTool) InsertQueryMultiple(tableName common.TableName, insertSchema common.InsertSchema, rows int) (string, error) {
-	if len(insertSchema.OrderedDbColumns) == 0 {
-		return "", errors.New("Can not insert 0 columns")
+func (this columnAttributes) definition(colDef common.ColDef) string {
+	definition := ""
+	if this.collation.Valid && colDef.GoType == reflect.String {
+		definition += " COLLATE " + this.collation.String
+	}
+	if this.defaultValue.Valid && strings.Contains(this.extra, "DEFAULT_GENERATED") {
+		definition += " DEFAULT (" + this.defaultValue.String + ")"
+	} else if this.defaultValue.Valid && colDef.GoType == reflect.String && colDef.Length == 0 {
+		// text column can have expression default only
+		definition += " DEFAULT (" + quote(this.defaultValue.String) + ")"
+	} else if this.defaultValue.Valid {
+		definition += " DEFAULT " + quote(this.defaultValue.String)
+	}
+	if strings.Contains(this.extra, "auto_increment") {
+		definition += " AUTO_INCREMENT"
 	}
+	if this.comment != "" {
+		definition += " COMMENT " + quote(this.comment)
+	}
+	return definition
+}
 
-	escapedNames := make([]string, len(insertSchema.OrderedDbColumns))
-	params := make([]string, len(insertSchema.OrderedDbColumns))
-	for i, name := range insertSchema.OrderedDbColumns {
-		escapedNames[i] = this.Escape(name)
-		params[i] = "?"
+func (this myDbTool) CreateTable(tableName common.TableName, tabSchema common.Schema) error {
+	query, err := this.CreateTableQuery(tableName, tabSchema)
+	if err != nil {
+		return err
 	}
+	logrus.Debug(query)
+	_, err = this.Db.Exec(query)
+	return err
+}
 
-	sb := bytes.NewBufferString("INSERT INTO ")
-	sb.WriteString(tableName.Schema)
-	sb.WriteString(".")
-	sb.WriteString(tableName.Table)
-	sb.WriteString("(")
-	sb.WriteString(strings.Join(escapedNames, ","))
-	sb.WriteString(") VALUES ")
-	for i := 0; i < rows; i++ {
-		if i > 0 {
-			sb.WriteString(",")
+// Text columns can not be keys without prefix length - so key columns are created as varchar.
+// Auto increment column should be a key, so it is unique if it is not primary key.
+func (this myDbTool) CreateTableQuery(tableName common.TableName, tabSchema common.Schema) (string, error) {
+	keySchema := tabSchema.Append()
+	keySchema.Keys = tabSchema.Keys
+	if keySchema.Keys.Identity != "" && len(keySchema.Keys.PrimaryKey) > 0 {
+		keySchema.Keys.Unique = append([][]string{{keySchema.Keys.Identity}}, keySchema.Keys.Unique...)
+	}
+	for _, name := range tabSchema.Keys.KeyColumns() {
+		colDef, found := keySchema.Get(name)
+		if found && colDef.GoType == reflect.String && colDef.Length == 0 {
+			colDef.Length = MAX_KEY_LENGTH
+			keySchema.Set(name, colDef)
 		}
-		sb.WriteString("(")
-		sb.WriteString(strings.Join(params, ","))
-		sb.WriteString(")")
 	}
-	return sb.String(), nil
+	return this.CommonDbTool.CreateTableQuery(tableName, keySchema)
+}
+
+// DDL statements commit transaction implicitly
+func (this myDbTool) TransactionalDdl() bool {
+	return false
+}
+
+func (this myDbTool) InsertStatement(tableName common.TableName, insertSchema common.InsertSchema) (string, error) {
+	if this.insertMethod == common.INSERT_METHOD_LOAD_DATA {
+		return loadDataQuery(this, "csv2db", tableName, insertSchema)
+	}
+	return this.InsertQuery(tableName, insertSchema)
 }
 
 func (this myDbTool) CreateInserter(tableName common.TableName, insertSchema common.InsertSchema) (common.Inserter, error) {
+	if this.insertMethod == common.INSERT_METHOD_LOAD_DATA {
+		ins, err := CreateLoadDataInserter(this.Db, this, tableName, insertSchema)
+		if err != nil {
+			return nil, err
+		}
+		return inserter.Background(&ins), nil
+	}
+
 	columnsCount := len(insertSchema.OrderedDbColumns)
 	maxRecordsPerBatch := 1
 	if columnsCount > 0 {
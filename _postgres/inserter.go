@@ -4,20 +4,20 @@ import (
 	"github.com/and-hom/csv2db/common"
 	"github.com/lib/pq"
 	"github.com/and-hom/csv2db/common/inserter"
-	"database/sql"
 	"github.com/sirupsen/logrus"
 )
 
-func CreateCopyInserter(db *sql.DB, dbTool common.DbTool, tableName common.TableName, insertSchema common.InsertSchema) (common.Inserter, error) {
+func CreateCopyInserter(db common.Db, dbTool common.DbTool, tableName common.TableName, insertSchema common.InsertSchema) (common.Inserter, error) {
 	tx, err := db.Begin()
 	if err != nil {
 		return nil, err
 	}
 
-	query := pq.CopyIn(tableName.TablePlain, insertSchema.OrderedDbColumns...)
+	query := pq.CopyInSchema(tableName.SchemaPlain, tableName.TablePlain, insertSchema.OrderedDbColumns...)
 	logrus.Debug("Query is " + query)
 	stmt, err := tx.Prepare(query)
 	if err != nil {
+		tx.Rollback()
 		return nil, err
 	}
 
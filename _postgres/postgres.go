@@ -1,20 +1,31 @@
 package _postgres
 
 import (
-	"database/sql"
 	"github.com/and-hom/csv2db/common"
 	"errors"
 	"reflect"
 	"github.com/sirupsen/logrus"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"bytes"
 	"strings"
 	"fmt"
 	"github.com/and-hom/csv2db/common/inserter"
 )
 
-func MakeDbTool(db *sql.DB) common.DbTool {
-	tool := pgDbTool{common.CommonDbTool{
+// Insert method is copy (default) or insert
+func MakeDbTool(db common.Db, insertMethod string) common.DbTool {
+	tool := makeDbTool(db)
+	tool.insertMethod = insertMethod
+	return tool
+}
+
+// Tool to generate queries without database connection
+func MakeDialect() common.DbTool {
+	return makeDbTool(nil)
+}
+
+func makeDbTool(db common.Db) pgDbTool {
+	tool := pgDbTool{CommonDbTool:common.CommonDbTool{
 		Db:db,
 		DbToGoTypeMapping:make(map[string]reflect.Kind),
 		GoTypeToDbMapping:make(map[reflect.Kind]string),
@@ -26,18 +37,25 @@ func MakeDbTool(db *sql.DB) common.DbTool {
 	tool.RegisterType(reflect.Int64, "bigint", "bigserial")
 	tool.RegisterType(reflect.Int32, "integer", "serial")
 	tool.RegisterType(reflect.Int8, "smallint", "smallserial")
-	tool.RegisterType(reflect.Float64, "double precision", "numeric")
+	tool.RegisterType(reflect.Float64, "double precision")
 	tool.RegisterType(reflect.Float32, "real")
-	tool.RegisterType(reflect.Bool, "bool")
-	tool.RegisterType(reflect.String, "character varying", "text", "character", "json", "jsonb", "uuid", "xml",
-		"date", "time", "timestamp",
-		"date with time zone", "time with time zone", "timestamp with time zone", )
+	tool.RegisterType(common.Decimal, "numeric", "decimal")
+	tool.RegisterType(reflect.Bool, "bool", "boolean")
+	tool.RegisterType(reflect.String, "character varying", "text", "character", "uuid", "xml",
+		"time with time zone")
+	tool.RegisterType(common.Date, "date")
+	tool.RegisterType(common.Time, "time", "time without time zone")
+	tool.RegisterType(common.Timestamp, "timestamp", "timestamp without time zone")
+	tool.RegisterType(common.TimestampTz, "timestamptz", "timestamp with time zone")
+	tool.RegisterType(common.Json, "jsonb", "json")
+	tool.IdentityType = "bigserial"
 
 	return tool
 }
 
 type pgDbTool struct {
 	common.CommonDbTool
+	insertMethod string
 }
 
 func (this pgDbTool) Exists(tableName common.TableName) (bool, error) {
@@ -101,20 +119,73 @@ func (this pgDbTool) LoadSchema(tableName common.TableName) (common.Schema, erro
 			return common.Schema{}, err
 		}
 
-		colDef.GoType, typeOk = this.DbToGoTypeMapping[dataType]
+		colDef.GoType, typeOk = this.DbToGoTypeMapping[common.BaseTypeName(dataType)]
 		if !typeOk {
 			logrus.Warnf("Can not detect go type for column type %s - skip column", dataType)
 			continue
 		}
+		if modifiers := common.TypeModifiers(dataType); colDef.GoType == common.Decimal && len(modifiers) > 0 {
+			colDef.Precision = modifiers[0]
+			if len(modifiers) > 1 {
+				colDef.Scale = modifiers[1]
+			}
+		} else if isVarchar(dataType) && len(modifiers) > 0 {
+			colDef.Length = modifiers[0]
+		}
 		schema.Add(colName, colDef)
 	}
 	return schema, nil
 }
 
+func (this pgDbTool) AlterColumnType(tableName common.TableName, name string, colDef common.ColDef) error {
+	query, err := this.AlterColumnTypeQuery(tableName, name, colDef)
+	if err != nil {
+		return err
+	}
+	logrus.Debug(query)
+	_, err = this.Db.Exec(query)
+	return err
+}
+
+// Explicit cast is needed to change text column to number and some other types
+func (this pgDbTool) AlterColumnTypeQuery(tableName common.TableName, name string, colDef common.ColDef) (string, error) {
+	query, err := this.CommonDbTool.AlterColumnTypeQuery(tableName, name, colDef)
+	if err != nil {
+		return "", err
+	}
+	sqlType, _ := this.SqlType(colDef)
+	return fmt.Sprintf("%s USING %s::%s", query, this.Escape(name), sqlType), nil
+}
+
+func isVarchar(dataType string) bool {
+	baseType := common.BaseTypeName(dataType)
+	return baseType == "character varying" || baseType == "character"
+}
+
+func (this pgDbTool) PrimaryKey(tableName common.TableName) ([]string, error) {
+	return this.QueryStrings(`SELECT a.attname
+					FROM pg_index i
+					JOIN pg_class c ON c.oid = i.indrelid
+					JOIN pg_namespace n ON n.oid = c.relnamespace
+					JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum = ANY (i.indkey)
+					WHERE i.indisprimary
+					    AND n.nspname = $1
+					    AND c.relname = $2
+					ORDER BY array_position(i.indkey::int2[], a.attnum)`,
+		tableName.SchemaPlain, tableName.TablePlain)
+}
+
 func (this pgDbTool) InsertQuery(tableName common.TableName, insertSchema common.InsertSchema) (string, error) {
 	return this.InsertQueryMultiple(tableName, insertSchema, 1)
 }
 
+func (this pgDbTool) InsertStatement(tableName common.TableName, insertSchema common.InsertSchema) (string, error) {
+	if this.insertMethod == common.INSERT_METHOD_INSERT {
+		return this.InsertQuery(tableName, insertSchema)
+	}
+	return pq.CopyInSchema(tableName.SchemaPlain, tableName.TablePlain, insertSchema.OrderedDbColumns...), nil
+}
+
 func (this pgDbTool) InsertQueryMultiple(tableName common.TableName, insertSchema common.InsertSchema, rows int) (string, error) {
 	rowParamCount := len(insertSchema.OrderedDbColumns)
 	if rowParamCount == 0 {
@@ -126,9 +197,7 @@ func (this pgDbTool) InsertQueryMultiple(tableName common.TableName, insertSchem
 	}
 
 	sb := bytes.NewBufferString("INSERT INTO ")
-	sb.WriteString(tableName.Schema)
-	sb.WriteString(".")
-	sb.WriteString(tableName.Table)
+	sb.WriteString(tableName.String())
 	sb.WriteString("(")
 	sb.WriteString(strings.Join(escapedNames, ","))
 	sb.WriteString(") VALUES ")
@@ -145,11 +214,23 @@ func (this pgDbTool) InsertQueryMultiple(tableName common.TableName, insertSchem
 		}
 		sb.WriteString(")")
 	}
+	sb.WriteString(this.UpsertClause(insertSchema))
 	return sb.String(), nil
 }
 
 func (this pgDbTool) CreateInserter(tableName common.TableName, insertSchema common.InsertSchema) (common.Inserter, error) {
-	ins, err := inserter.CreateBufferedTxInserter(this.Db, this, tableName, insertSchema, 1000 / len(insertSchema.OrderedDbColumns))
+	var ins common.Inserter
+	var err error
+	if this.insertMethod == common.INSERT_METHOD_INSERT {
+		columnsCount := len(insertSchema.OrderedDbColumns)
+		maxRecordsPerBatch := 1
+		if columnsCount > 0 {
+			maxRecordsPerBatch = 1000 / columnsCount
+		}
+		ins, err = inserter.CreateBufferedTxInserter(this.Db, this, tableName, insertSchema, maxRecordsPerBatch)
+	} else {
+		ins, err = CreateCopyInserter(this.Db, this, tableName, insertSchema)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,95 @@
+package _script
+
+import (
+	"github.com/and-hom/csv2db/common"
+	"io"
+	"fmt"
+	"bytes"
+	"github.com/olekukonko/tablewriter"
+)
+
+// Reads table existence and schema from database and prints data changing queries instead of executing them.
+// Rows are not inserted - first previewRows converted rows are printed.
+func MakeDryRunDbTool(dbTool common.DbTool, out io.Writer, previewRows int) common.DbTool {
+	return dryRunDbTool{
+		scriptDbTool:scriptDbTool{DbTool:dbTool, out:out},
+		previewRows:previewRows,
+	}
+}
+
+type dryRunDbTool struct {
+	scriptDbTool
+	previewRows int
+}
+
+func (this dryRunDbTool) Exists(tableName common.TableName) (bool, error) {
+	return this.DbTool.Exists(tableName)
+}
+
+func (this dryRunDbTool) LoadSchema(tableName common.TableName) (common.Schema, error) {
+	return this.DbTool.LoadSchema(tableName)
+}
+
+func (this dryRunDbTool) PrimaryKey(tableName common.TableName) ([]string, error) {
+	return this.DbTool.PrimaryKey(tableName)
+}
+
+func (this dryRunDbTool) CreateEmptyCopy(tableName, source common.TableName) error {
+	return this.write(this.CreateEmptyCopyQuery(tableName, source))
+}
+
+func (this dryRunDbTool) CreateInserter(tableName common.TableName, insertSchema common.InsertSchema) (common.Inserter, error) {
+	query, err := this.InsertStatement(tableName, insertSchema)
+	if err != nil {
+		return nil, err
+	}
+	_, err = fmt.Fprintf(this.out, "-- Insert schema\n%s\n%s;\n\n", insertSchema.ToAsciiTable(), query)
+	if err != nil {
+		return nil, err
+	}
+	return &previewInserter{
+		out:this.out,
+		columns:insertSchema.OrderedDbColumns,
+		literal:this.Literal,
+		previewRows:this.previewRows,
+	}, nil
+}
+
+// Collects first rows and prints them as table on close
+type previewInserter struct {
+	out         io.Writer
+	columns     []string
+	literal     func(interface{}) string
+	previewRows int
+	rows        [][]string
+	count       int
+}
+
+func (this *previewInserter) Add(values ...interface{}) error {
+	this.count++
+	if len(this.rows) >= this.previewRows {
+		return nil
+	}
+	literals := make([]string, len(values))
+	for i, value := range values {
+		literals[i] = this.literal(value)
+	}
+	this.rows = append(this.rows, literals)
+	return nil
+}
+
+func (this *previewInserter) Close() error {
+	buf := bytes.Buffer{}
+	table := tablewriter.NewWriter(&buf)
+	table.SetAutoFormatHeaders(false)
+	table.SetHeader(this.columns)
+	table.SetCenterSeparator("|")
+	table.AppendBulk(this.rows)
+	table.Render()
+	_, err := fmt.Fprintf(this.out, "-- First %d of %d rows\n%s\n", len(this.rows), this.count, buf.String())
+	return err
+}
+
+func (this *previewInserter) Abort() error {
+	return nil
+}
@@ -0,0 +1,149 @@
+package _script
+
+import (
+	"github.com/and-hom/csv2db/common"
+	"io"
+	"fmt"
+	"strings"
+)
+
+const ROWS_PER_INSERT = 100
+
+// Rows are written in transaction, so aborted load does not leave part of rows in script
+func beginTx(out io.Writer) error {
+	_, err := io.WriteString(out, "BEGIN;\n\n")
+	return err
+}
+
+func endTx(out io.Writer, command string) error {
+	_, err := fmt.Fprintf(out, "%s;\n\n", command)
+	return err
+}
+
+// Writes multiple rows INSERT statements with literal values
+type insertInserter struct {
+	dialect      common.DbTool
+	out          io.Writer
+	insertSchema common.InsertSchema
+	header       string
+	footer       string
+	rows         [][]string
+	// nil if upsert is disabled
+	keys         *common.BatchKeys
+}
+
+func createInsertInserter(dialect common.DbTool, out io.Writer, tableName common.TableName, insertSchema common.InsertSchema) (common.Inserter, error) {
+	if err := beginTx(out); err != nil {
+		return nil, err
+	}
+	return &insertInserter{
+		dialect:dialect,
+		out:out,
+		insertSchema:insertSchema,
+		header:fmt.Sprintf("INSERT INTO %s(%s) VALUES", tableName.String(), escapedColumns(dialect, insertSchema)),
+		footer:dialect.UpsertClause(insertSchema),
+		rows:make([][]string, 0, ROWS_PER_INSERT),
+		keys:common.NewBatchKeys(insertSchema),
+	}, nil
+}
+
+func (this *insertInserter) Add(values ...interface{}) error {
+	literals := make([]string, len(values))
+	for i, value := range values {
+		literals[i] = this.dialect.Literal(value)
+	}
+	if this.keys != nil {
+		if earlier, found := this.keys.Find(values, len(this.rows)); found {
+			for _, index := range this.keys.UpdateIndexes() {
+				this.rows[earlier][index] = literals[index]
+			}
+			return nil
+		}
+	}
+	this.rows = append(this.rows, literals)
+	if len(this.rows) >= ROWS_PER_INSERT {
+		return this.flush()
+	}
+	return nil
+}
+
+func (this *insertInserter) flush() error {
+	if len(this.rows) == 0 {
+		return nil
+	}
+	rows := make([]string, len(this.rows))
+	for i, literals := range this.rows {
+		rows[i] = "(" + strings.Join(literals, ",") + ")"
+	}
+	_, err := fmt.Fprintf(this.out, "%s\n%s%s;\n\n", this.header, strings.Join(rows, ",\n"), this.footer)
+	this.rows = this.rows[:0]
+	if this.keys != nil {
+		this.keys.Reset()
+	}
+	return err
+}
+
+func (this *insertInserter) Close() error {
+	if err := this.flush(); err != nil {
+		return err
+	}
+	return endTx(this.out, "COMMIT")
+}
+
+// Already written statements are rolled back by script
+func (this *insertInserter) Abort() error {
+	this.rows = this.rows[:0]
+	return endTx(this.out, "ROLLBACK")
+}
+
+var copyFormat = common.TextRowFormat{True:"t", False:"f"}
+
+// Writes PostgreSQL COPY FROM stdin block
+type copyInserter struct {
+	out          io.Writer
+	insertSchema common.InsertSchema
+}
+
+func createCopyInserter(dialect common.DbTool, out io.Writer, tableName common.TableName, insertSchema common.InsertSchema) (common.Inserter, error) {
+	if err := beginTx(out); err != nil {
+		return nil, err
+	}
+	_, err := fmt.Fprintf(out, "COPY %s (%s) FROM stdin;\n", tableName.String(), escapedColumns(dialect, insertSchema))
+	if err != nil {
+		return nil, err
+	}
+	return &copyInserter{out:out, insertSchema:insertSchema}, nil
+}
+
+func (this *copyInserter) Add(values ...interface{}) error {
+	_, err := io.WriteString(this.out, copyFormat.Format(values))
+	return err
+}
+
+func (this *copyInserter) Close() error {
+	if err := this.endCopy(); err != nil {
+		return err
+	}
+	return endTx(this.out, "COMMIT")
+}
+
+// COPY block should be terminated anyway
+func (this *copyInserter) Abort() error {
+	if err := this.endCopy(); err != nil {
+		return err
+	}
+	return endTx(this.out, "ROLLBACK")
+}
+
+func (this *copyInserter) endCopy() error {
+	_, err := io.WriteString(this.out, "\\.\n\n")
+	return err
+}
+
+func escapedColumns(dialect common.DbTool, insertSchema common.InsertSchema) string {
+	escapedNames := make([]string, len(insertSchema.OrderedDbColumns))
+	for i, name := range insertSchema.OrderedDbColumns {
+		escapedNames[i] = dialect.Escape(name)
+	}
+	return strings.Join(escapedNames, ",")
+}
@@ -0,0 +1,137 @@
+package _script
+
+import (
+	"github.com/and-hom/csv2db/common"
+	"io"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"os"
+	"bufio"
+	"errors"
+)
+
+// Writes all queries into SQL script instead of executing them. Queries are generated by dialect tool.
+// Table existence can not be checked - so it should be known in advance.
+func MakeDbTool(dialect common.DbTool, out io.Writer, tableExists bool, insertMethod string) common.DbTool {
+	return scriptDbTool{
+		DbTool:dialect,
+		out:out,
+		tableExists:tableExists,
+		insertMethod:insertMethod,
+	}
+}
+
+type scriptDbTool struct {
+	common.DbTool
+	out          io.Writer
+	tableExists  bool
+	insertMethod string
+}
+
+func (this scriptDbTool) Exists(tableName common.TableName) (bool, error) {
+	return this.tableExists, nil
+}
+
+func (this scriptDbTool) LoadSchema(tableName common.TableName) (common.Schema, error) {
+	return common.Schema{}, common.ErrNoCatalog
+}
+
+func (this scriptDbTool) PrimaryKey(tableName common.TableName) ([]string, error) {
+	return nil, common.ErrNoCatalog
+}
+
+func (this scriptDbTool) CreateTable(tableName common.TableName, tabSchema common.Schema) error {
+	query, err := this.CreateTableQuery(tableName, tabSchema)
+	if err != nil {
+		return err
+	}
+	return this.write(query)
+}
+
+func (this scriptDbTool) DeleteFromTable(tableName common.TableName) error {
+	return this.write(this.DeleteFromTableQuery(tableName))
+}
+
+func (this scriptDbTool) TruncateTable(tableName common.TableName) error {
+	return this.write(this.TruncateTableQuery(tableName))
+}
+
+func (this scriptDbTool) DropTable(tableName common.TableName) error {
+	return this.write(this.DropTableQuery(tableName))
+}
+
+func (this scriptDbTool) AddColumn(tableName common.TableName, name string, colDef common.ColDef) error {
+	query, err := this.AddColumnQuery(tableName, name, colDef)
+	if err != nil {
+		return err
+	}
+	return this.write(query)
+}
+
+func (this scriptDbTool) CreateIndex(tableName common.TableName, columns []string) error {
+	return this.write(this.CreateIndexQuery(tableName, columns))
+}
+
+func (this scriptDbTool) AlterColumnType(tableName common.TableName, name string, colDef common.ColDef) error {
+	query, err := this.AlterColumnTypeQuery(tableName, name, colDef)
+	if err != nil {
+		return err
+	}
+	return this.write(query)
+}
+
+func (this scriptDbTool) ExecInTx(queries ...string) ([]int64, error) {
+	for _, query := range queries {
+		if query == "" {
+			continue
+		}
+		if err := this.write(query); err != nil {
+			return nil, err
+		}
+	}
+	// affected rows are not known
+	return make([]int64, len(queries)), nil
+}
+
+func (this scriptDbTool) CreateInserter(tableName common.TableName, insertSchema common.InsertSchema) (common.Inserter, error) {
+	if this.insertMethod == common.INSERT_METHOD_COPY {
+		return createCopyInserter(this.DbTool, this.out, tableName, insertSchema)
+	}
+	return createInsertInserter(this.DbTool, this.out, tableName, insertSchema)
+}
+
+func (this scriptDbTool) write(query string) error {
+	logrus.Debug(query)
+	_, err := fmt.Fprintf(this.out, "%s;\n\n", query)
+	return err
+}
+
+// Open script file. Use -- to write into stdout.
+func OpenOutput(fileName string) (io.WriteCloser, error) {
+	if fileName == "" {
+		return nil, errors.New("Script file name is empty")
+	}
+	if fileName == "--" {
+		return &output{Writer:bufio.NewWriter(os.Stdout)}, nil
+	}
+	file, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return &output{Writer:bufio.NewWriter(file), file:file}, nil
+}
+
+type output struct {
+	*bufio.Writer
+	file *os.File
+}
+
+func (this *output) Close() error {
+	err := this.Flush()
+	if this.file != nil {
+		if closeErr := this.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,207 @@
+package _sqlite
+
+import (
+	"github.com/and-hom/csv2db/common"
+	"errors"
+	"reflect"
+	"github.com/sirupsen/logrus"
+	_ "github.com/mattn/go-sqlite3"
+	"strings"
+	"fmt"
+	"github.com/and-hom/csv2db/common/inserter"
+)
+
+// Default SQLITE_MAX_VARIABLE_NUMBER for SQLite before 3.32
+const MAX_VARIABLES = 999
+
+func MakeDbTool(db common.Db) common.DbTool {
+	return makeDbTool(db)
+}
+
+// Tool to generate queries without database connection
+func MakeDialect() common.DbTool {
+	return makeDbTool(nil)
+}
+
+func makeDbTool(db common.Db) sqliteDbTool {
+	tool := sqliteDbTool{common.CommonDbTool{
+		Db:db,
+		DbToGoTypeMapping:make(map[string]reflect.Kind),
+		GoTypeToDbMapping:make(map[reflect.Kind]string),
+		DefaultSchema:"main",
+		EscapeF:func(s string) string {
+			return "\"" + s + "\""
+		},
+	}, }
+	tool.RegisterType(reflect.Int32, "integer")
+	tool.RegisterType(reflect.Int8, "smallint")
+	// all SQLite integers are 64 bit - so load any integer column as int64
+	tool.RegisterType(reflect.Int64, "bigint", "integer", "int", "smallint", "tinyint", "mediumint",
+		"int2", "int4", "int8", "unsigned big int")
+	tool.RegisterType(reflect.Float64, "double", "double precision", "real", "float")
+	tool.RegisterType(reflect.Bool, "boolean", "bool")
+	tool.RegisterType(common.Decimal, "numeric", "decimal")
+	tool.RegisterType(common.Date, "date")
+	tool.RegisterType(common.Time, "time")
+	tool.RegisterType(common.Timestamp, "datetime", "timestamp")
+	tool.RegisterType(common.TimestampTz, "timestamptz")
+	tool.RegisterType(common.Json, "json")
+	tool.RegisterType(reflect.String, "text", "varchar", "character varying", "char", "character", "clob", "")
+	// single integer primary key column is an alias of auto increment rowid
+	tool.IdentityType = "integer"
+
+	return tool
+}
+
+type sqliteDbTool struct {
+	common.CommonDbTool
+}
+
+func (this sqliteDbTool) Exists(tableName common.TableName) (bool, error) {
+	query := fmt.Sprintf(`SELECT COUNT(*)
+			FROM %s.sqlite_master
+			WHERE type = 'table'
+			AND name = ?`, tableName.Schema)
+	logrus.Debug(query)
+	rows, err := this.Db.Query(query, tableName.TablePlain)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		result := 0
+		err := rows.Scan(&result)
+		return result > 0, err
+	}
+	return false, errors.New("Empty query for select exists")
+}
+
+func (this sqliteDbTool) LoadSchema(tableName common.TableName) (common.Schema, error) {
+	rows, err := this.Db.Query(`SELECT name, "notnull", type
+					FROM pragma_table_info(?, ?)
+					ORDER BY cid ASC`, tableName.TablePlain, tableName.SchemaPlain)
+	if err != nil {
+		return common.Schema{}, err
+	}
+	defer rows.Close()
+
+	schema := common.NewSchema()
+	i := 0
+	for rows.Next() {
+		colName := ""
+		dataType := ""
+		notNull := false
+		colDef := common.ColDef{OrderIndex:i}
+		i += 1
+
+		err := rows.Scan(&colName, &notNull, &dataType)
+		if err != nil {
+			return common.Schema{}, err
+		}
+		colDef.Nullable = !notNull
+		colDef.GoType = this.goType(dataType)
+		if modifiers := common.TypeModifiers(dataType); colDef.GoType == common.Decimal && len(modifiers) > 0 {
+			colDef.Precision = modifiers[0]
+			if len(modifiers) > 1 {
+				colDef.Scale = modifiers[1]
+			}
+		}
+		schema.Add(colName, colDef)
+	}
+	return schema, nil
+}
+
+func (this sqliteDbTool) PrimaryKey(tableName common.TableName) ([]string, error) {
+	return this.QueryStrings(`SELECT name
+					FROM pragma_table_info(?, ?)
+					WHERE pk > 0
+					ORDER BY pk ASC`, tableName.TablePlain, tableName.SchemaPlain)
+}
+
+// SQLite accepts any declared type. Use registered type or the type affinity rules from
+// https://www.sqlite.org/datatype3.html#determination_of_column_affinity
+func (this sqliteDbTool) goType(dataType string) reflect.Kind {
+	baseType := strings.ToLower(common.BaseTypeName(dataType))
+	if goType, found := this.DbToGoTypeMapping[baseType]; found {
+		return goType
+	}
+	switch {
+	case strings.Contains(baseType, "int"):
+		return reflect.Int64
+	case strings.Contains(baseType, "char"), strings.Contains(baseType, "clob"), strings.Contains(baseType, "text"):
+		return reflect.String
+	case strings.Contains(baseType, "blob"):
+		logrus.Warnf("Blob column type %s - insert CSV values as text", dataType)
+		return reflect.String
+	case strings.Contains(baseType, "real"), strings.Contains(baseType, "floa"), strings.Contains(baseType, "doub"):
+		return reflect.Float64
+	default:
+		return common.Decimal
+	}
+}
+
+func (this sqliteDbTool) AlterColumnType(tableName common.TableName, name string, colDef common.ColDef) error {
+	_, err := this.AlterColumnTypeQuery(tableName, name, colDef)
+	return err
+}
+
+func (this sqliteDbTool) AlterColumnTypeQuery(tableName common.TableName, name string, colDef common.ColDef) (string, error) {
+	return "", errors.New("SQLite can not change column type")
+}
+
+func (this sqliteDbTool) CreateTable(tableName common.TableName, tabSchema common.Schema) error {
+	query, err := this.CreateTableQuery(tableName, tabSchema)
+	if err != nil {
+		return err
+	}
+	logrus.Debug(query)
+	_, err = this.Db.Exec(query)
+	return err
+}
+
+func (this sqliteDbTool) CreateTableQuery(tableName common.TableName, tabSchema common.Schema) (string, error) {
+	if tabSchema.Keys.Identity != "" && len(tabSchema.Keys.PrimaryKey) > 0 {
+		return "", errors.New("SQLite identity column should be primary key")
+	}
+	return this.CommonDbTool.CreateTableQuery(tableName, tabSchema)
+}
+
+// Index is created in the schema of table
+func (this sqliteDbTool) CreateIndex(tableName common.TableName, columns []string) error {
+	query := this.CreateIndexQuery(tableName, columns)
+	logrus.Debug(query)
+	_, err := this.Db.Exec(query)
+	return err
+}
+
+func (this sqliteDbTool) CreateIndexQuery(tableName common.TableName, columns []string) string {
+	escapedNames := make([]string, len(columns))
+	for i, name := range columns {
+		escapedNames[i] = this.Escape(name)
+	}
+	return fmt.Sprintf("CREATE INDEX %s.%s ON %s (%s)", tableName.Schema, this.Escape(common.IndexName(tableName, columns)),
+		tableName.Table, strings.Join(escapedNames, ","))
+}
+
+// SQLite has no TRUNCATE statement. DELETE without WHERE uses the truncate optimization.
+func (this sqliteDbTool) TruncateTable(tableName common.TableName) error {
+	return this.DeleteFromTable(tableName)
+}
+
+func (this sqliteDbTool) TruncateTableQuery(tableName common.TableName) string {
+	return this.DeleteFromTableQuery(tableName)
+}
+
+func (this sqliteDbTool) CreateInserter(tableName common.TableName, insertSchema common.InsertSchema) (common.Inserter, error) {
+	columnsCount := len(insertSchema.OrderedDbColumns)
+	maxRecordsPerBatch := 1
+	if columnsCount > 0 && columnsCount < MAX_VARIABLES {
+		maxRecordsPerBatch = MAX_VARIABLES / columnsCount
+	}
+	ins, err := inserter.CreateBufferedTxInserter(this.Db, this, tableName, insertSchema, maxRecordsPerBatch)
+	if err != nil {
+		return nil, err
+	}
+	return inserter.Background(&ins), nil
+}
@@ -1,14 +1,18 @@
 package common
 
 import (
-	"database/sql"
 	"reflect"
 	"bytes"
 	"github.com/sirupsen/logrus"
 	"fmt"
 	"errors"
+	"strings"
+	"time"
 )
 
+// Returned by DbTool that can not read table definitions
+var ErrNoCatalog = errors.New("Database catalog is not available")
+
 type DbTool interface {
 	TableName(schema, table string) TableName
 	CreateInserter(tableName TableName, insertSchema InsertSchema) (Inserter, error)
@@ -19,16 +23,51 @@ type DbTool interface {
 	DeleteFromTable(tableName TableName) error
 	TruncateTable(tableName TableName) error
 	DropTable(tableName TableName) error
+	AddColumn(tableName TableName, name string, colDef ColDef) error
+	CreateIndex(tableName TableName, columns []string) error
+	AlterColumnType(tableName TableName, name string, colDef ColDef) error
+	// Create table with columns of source table without rows and constraints
+	CreateEmptyCopy(tableName, source TableName) error
+
+	CreateTableQuery(tableName TableName, tabSchema Schema) (string, error)
+	DeleteFromTableQuery(tableName TableName) string
+	TruncateTableQuery(tableName TableName) string
+	DropTableQuery(tableName TableName) string
+	AddColumnQuery(tableName TableName, name string, colDef ColDef) (string, error)
+	CreateIndexQuery(tableName TableName, columns []string) string
+	AlterColumnTypeQuery(tableName TableName, name string, colDef ColDef) (string, error)
 	InsertQuery(tableName TableName, tabSchema InsertSchema) (string, error)
 	InsertQueryMultiple(tableName TableName, tabSchema InsertSchema, rows int) (string, error)
+	CreateEmptyCopyQuery(tableName, source TableName) string
+	// Statement executed by inserter of CreateInserter to load rows
+	InsertStatement(tableName TableName, insertSchema InsertSchema) (string, error)
+	// Escape column name
+	Escape(name string) string
+	// SQL literal for value produced by ValMapper
+	Literal(value interface{}) string
+	// Conflict resolution clause of INSERT query for InsertSchema.Upsert
+	UpsertClause(insertSchema InsertSchema) string
+	// Primary key columns. Empty if table has no primary key
+	PrimaryKey(tableName TableName) ([]string, error)
+	// Queries to make target table rows equal to staging table rows
+	SyncQueries(target, staging TableName, sync Sync) SyncQueries
+	// Execute queries in one transaction and return affected rows count of every query
+	ExecInTx(queries ...string) ([]int64, error)
+	// True if DDL statements can be rolled back
+	TransactionalDdl() bool
 }
 
 type CommonDbTool struct {
-	Db                *sql.DB
+	Db                Db
 	DbToGoTypeMapping map[string]reflect.Kind
 	GoTypeToDbMapping map[reflect.Kind]string
 	DefaultSchema     string
 	EscapeF           func(string) string
+	QuoteF            func(string) string
+	// Custom conflict resolution clause. Standard ON CONFLICT is used if nil
+	UpsertClauseF     func(InsertSchema) string
+	// Type of auto increment column
+	IdentityType      string
 }
 
 func (this CommonDbTool) TableName(schema, table string) TableName {
@@ -50,16 +89,32 @@ func (this CommonDbTool) RegisterType(goType reflect.Kind, dbPrimaryType string,
 }
 
 func (this CommonDbTool) CreateTable(tableName TableName, tabSchema Schema) error {
+	query, err := this.CreateTableQuery(tableName, tabSchema)
+	if err != nil {
+		return err
+	}
+	return this.exec(query)
+}
+
+func (this CommonDbTool) CreateTableQuery(tableName TableName, tabSchema Schema) (string, error) {
 	if tabSchema.Len() == 0 {
-		return errors.New("Can not create table without any column")
+		return "", errors.New("Can not create table without any column")
 	}
 	sb := bytes.NewBufferString("CREATE TABLE ")
-	sb.WriteString(tableName.Schema)
-	sb.WriteString(".")
-	sb.WriteString(tableName.Table)
+	sb.WriteString(tableName.String())
 	sb.WriteString("(")
 
 	first := true
+	if identity := tabSchema.Keys.Identity; identity != "" {
+		if _, found := tabSchema.Get(identity); found {
+			return "", fmt.Errorf("Identity column %s is also a table column", identity)
+		}
+		if this.IdentityType == "" {
+			return "", errors.New("Identity column is not supported")
+		}
+		sb.WriteString(this.Escape(identity) + " " + this.IdentityType + " NOT NULL")
+		first = false
+	}
 	for _, name := range tabSchema.OrderedDbColumns {
 		colDef, found := tabSchema.Get(name)
 		if !found {
@@ -71,40 +126,209 @@ func (this CommonDbTool) CreateTable(tableName TableName, tabSchema Schema) erro
 		} else {
 			sb.WriteString(", ")
 		}
-		sqlType, registered := this.GoTypeToDbMapping[colDef.GoType]
-		if !registered {
-			return fmt.Errorf("No registered SQL type for go type %v", colDef.GoType)
-		}
-		sb.WriteString(this.Escape(name))
-		sb.WriteString(" ")
-		sb.WriteString(sqlType)
-		if !colDef.Nullable {
-			sb.WriteString(" NOT NULL")
+		columnDefinition, err := this.ColumnDefinition(name, colDef)
+		if err != nil {
+			return "", err
 		}
+		sb.WriteString(columnDefinition)
+	}
+	constraints, err := this.keyConstraints(tabSchema)
+	if err != nil {
+		return "", err
+	}
+	for _, constraint := range constraints {
+		sb.WriteString(", ")
+		sb.WriteString(constraint)
 	}
 	sb.WriteString(")")
+	return sb.String(), nil
+}
 
-	logrus.Debug(sb.String())
+// Column name, type and nullability for CREATE TABLE and ALTER TABLE queries
+func (this CommonDbTool) ColumnDefinition(name string, colDef ColDef) (string, error) {
+	sqlType, err := this.SqlType(colDef)
+	if err != nil {
+		return "", err
+	}
+	definition := this.Escape(name) + " " + sqlType
+	if !colDef.Nullable {
+		definition += " NOT NULL"
+	}
+	return definition, nil
+}
 
-	_, err := this.Db.Exec(sb.String())
-	return err
+func (this CommonDbTool) SqlType(colDef ColDef) (string, error) {
+	sqlType, registered := this.GoTypeToDbMapping[colDef.GoType]
+	if !registered {
+		return "", fmt.Errorf("No registered SQL type for go type %s", TypeName(colDef.GoType))
+	}
+	if colDef.GoType == reflect.String && colDef.Length > 0 {
+		return fmt.Sprintf("varchar(%d)", colDef.Length), nil
+	}
+	if colDef.Precision > 0 {
+		// registered type may have default precision like decimal(65,30)
+		return fmt.Sprintf("%s(%d,%d)", BaseTypeName(sqlType), colDef.Precision, colDef.Scale), nil
+	}
+	return sqlType, nil
+}
+
+func (this CommonDbTool) CreateEmptyCopy(tableName, source TableName) error {
+	return this.exec(this.CreateEmptyCopyQuery(tableName, source))
+}
+
+func (this CommonDbTool) CreateEmptyCopyQuery(tableName, source TableName) string {
+	return fmt.Sprintf("CREATE TABLE %s AS SELECT * FROM %s WHERE 1 = 0", tableName.String(), source.String())
 }
 
 func (this CommonDbTool) DropTable(tableName TableName) error {
-	_, err := this.Db.Exec(fmt.Sprintf("DROP TABLE %s.%s", tableName.Schema, tableName.Table))
-	return err
+	return this.exec(this.DropTableQuery(tableName))
+}
+
+func (this CommonDbTool) DropTableQuery(tableName TableName) string {
+	return "DROP TABLE " + tableName.String()
+}
+
+func (this CommonDbTool) AddColumn(tableName TableName, name string, colDef ColDef) error {
+	query, err := this.AddColumnQuery(tableName, name, colDef)
+	if err != nil {
+		return err
+	}
+	return this.exec(query)
+}
+
+func (this CommonDbTool) AddColumnQuery(tableName TableName, name string, colDef ColDef) (string, error) {
+	columnDefinition, err := this.ColumnDefinition(name, colDef)
+	if err != nil {
+		return "", err
+	}
+	return "ALTER TABLE " + tableName.String() + " ADD COLUMN " + columnDefinition, nil
+}
+
+func (this CommonDbTool) AlterColumnType(tableName TableName, name string, colDef ColDef) error {
+	query, err := this.AlterColumnTypeQuery(tableName, name, colDef)
+	if err != nil {
+		return err
+	}
+	return this.exec(query)
+}
+
+func (this CommonDbTool) AlterColumnTypeQuery(tableName TableName, name string, colDef ColDef) (string, error) {
+	sqlType, err := this.SqlType(colDef)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s", tableName.String(), this.Escape(name), sqlType), nil
 }
 
 func (this CommonDbTool) TruncateTable(tableName TableName) error {
-	_, err := this.Db.Exec(fmt.Sprintf("TRUNCATE TABLE %s.%s", tableName.Schema, tableName.Table))
-	return err
+	return this.exec(this.TruncateTableQuery(tableName))
+}
+
+func (this CommonDbTool) TruncateTableQuery(tableName TableName) string {
+	return "TRUNCATE TABLE " + tableName.String()
 }
 
 func (this CommonDbTool) DeleteFromTable(tableName TableName) error {
-	_, err := this.Db.Exec(fmt.Sprintf("DELETE FROM %s.%s", tableName.Schema, tableName.Table))
+	return this.exec(this.DeleteFromTableQuery(tableName))
+}
+
+func (this CommonDbTool) DeleteFromTableQuery(tableName TableName) string {
+	return "DELETE FROM " + tableName.String()
+}
+
+func (this CommonDbTool) TransactionalDdl() bool {
+	return true
+}
+
+func (this CommonDbTool) exec(query string) error {
+	logrus.Debug(query)
+	_, err := this.Db.Exec(query)
 	return err
 }
 
+// Values of the first column of query result
+func (this CommonDbTool) QueryStrings(query string, args ...interface{}) ([]string, error) {
+	logrus.Debug(query)
+	rows, err := this.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]string, 0)
+	for rows.Next() {
+		value := ""
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+	}
+	return result, rows.Err()
+}
+
+func (this CommonDbTool) InsertQuery(tableName TableName, insertSchema InsertSchema) (string, error) {
+	return this.InsertQueryMultiple(tableName, insertSchema, 1)
+}
+
+func (this CommonDbTool) InsertStatement(tableName TableName, insertSchema InsertSchema) (string, error) {
+	return this.InsertQuery(tableName, insertSchema)
+}
+
+// Multiple rows insert query with ? placeholders
+func (this CommonDbTool) InsertQueryMultiple(tableName TableName, insertSchema InsertSchema, rows int) (string, error) {
+	if len(insertSchema.OrderedDbColumns) == 0 {
+		return "", errors.New("Can not insert 0 columns")
+	}
+
+	escapedNames := make([]string, len(insertSchema.OrderedDbColumns))
+	params := make([]string, len(insertSchema.OrderedDbColumns))
+	for i, name := range insertSchema.OrderedDbColumns {
+		escapedNames[i] = this.Escape(name)
+		params[i] = "?"
+	}
+
+	sb := bytes.NewBufferString("INSERT INTO ")
+	sb.WriteString(tableName.String())
+	sb.WriteString("(")
+	sb.WriteString(strings.Join(escapedNames, ","))
+	sb.WriteString(") VALUES ")
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("(")
+		sb.WriteString(strings.Join(params, ","))
+		sb.WriteString(")")
+	}
+	sb.WriteString(this.UpsertClause(insertSchema))
+	return sb.String(), nil
+}
+
+func (this CommonDbTool) Literal(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return this.Quote(v)
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case time.Time:
+		return this.Quote(v.Format(TIMESTAMP_TZ_LAYOUT))
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (this CommonDbTool) Quote(v string) string {
+	if this.QuoteF == nil {
+		return "'" + strings.Replace(v, "'", "''", -1) + "'"
+	}
+	return this.QuoteF(v)
+}
+
 func (this CommonDbTool) NvlSchema(schema string) string {
 	if schema == "" {
 		return this.DefaultSchema
@@ -127,5 +351,8 @@ type TableName struct {
 }
 
 func (this TableName) String() string {
+	if this.SchemaPlain == "" {
+		return this.Table
+	}
 	return this.Schema + "." + this.Table
 }
\ No newline at end of file
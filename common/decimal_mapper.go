@@ -0,0 +1,42 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var decimalRegexp = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)$`)
+
+// Keeps exact textual value of decimal checking it fits precision and scale.
+// Zero precision means unconstrained decimal.
+type DecimalMapper struct {
+	Precision int
+	Scale     int
+}
+
+func (this DecimalMapper) Apply(val string) (interface{}, error) {
+	val = strings.TrimSpace(val)
+	intDigits, scale, ok := DecimalDigits(val)
+	if !ok {
+		return nil, fmt.Errorf("Can not parse decimal %s", val)
+	}
+	if this.Precision > 0 && (intDigits > this.Precision - this.Scale || scale > this.Scale) {
+		return nil, fmt.Errorf("Decimal %s does not fit precision %d and scale %d", val, this.Precision, this.Scale)
+	}
+	return val, nil
+}
+
+// Count significant integer digits and fraction digits of decimal text
+func DecimalDigits(val string) (int, int, bool) {
+	if !decimalRegexp.MatchString(val) {
+		return 0, 0, false
+	}
+	parts := strings.SplitN(strings.TrimLeft(val, "+-"), ".", 2)
+	intDigits := len(strings.TrimLeft(parts[0], "0"))
+	scale := 0
+	if len(parts) > 1 {
+		scale = len(parts[1])
+	}
+	return intDigits, scale, true
+}
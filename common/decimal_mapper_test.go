@@ -0,0 +1,20 @@
+package common
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecimalMapper(t *testing.T) {
+	mapper := DecimalMapper{Precision:5, Scale:2}
+	value, err := mapper.Apply("-123.45")
+	assert.Nil(t, err)
+	assert.Equal(t, "-123.45", value)
+
+	_, err = mapper.Apply("1234.5")
+	assert.NotNil(t, err)
+	_, err = mapper.Apply("1.234")
+	assert.NotNil(t, err)
+	_, err = mapper.Apply("1e5")
+	assert.NotNil(t, err)
+}
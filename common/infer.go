@@ -0,0 +1,158 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Wider decimal columns are created as float
+const MAX_INFERRED_PRECISION = 38
+
+var dateMapper = NewTimeMapper(Date, "", time.UTC)
+var timestampMapper = NewTimeMapper(Timestamp, "", time.UTC)
+
+// Collects sample values for each CSV column and picks the narrowest type
+// and nullability that fits all of them
+type TypeInferrer struct {
+	columns []columnCandidates
+}
+
+type columnCandidates struct {
+	hasEmpty    bool
+	hasValues   bool
+	isInt8      bool
+	isInt32     bool
+	isInt64     bool
+	isFloat64   bool
+	isDecimal   bool
+	intDigits   int
+	scale       int
+	isBool      bool
+	isDate      bool
+	isTimestamp bool
+	isJson      bool
+}
+
+func newColumnCandidates() columnCandidates {
+	return columnCandidates{
+		isInt8:true,
+		isInt32:true,
+		isInt64:true,
+		isFloat64:true,
+		isDecimal:true,
+		isBool:true,
+		isDate:true,
+		isTimestamp:true,
+		isJson:true,
+	}
+}
+
+func NewTypeInferrer() *TypeInferrer {
+	return &TypeInferrer{columns:make([]columnCandidates, 0)}
+}
+
+func (this *TypeInferrer) Add(line []string) {
+	for len(this.columns) < len(line) {
+		this.columns = append(this.columns, newColumnCandidates())
+	}
+	for i, val := range line {
+		this.columns[i].add(val)
+	}
+}
+
+// Set go type and nullable flag for every column of schema by its order index
+func (this *TypeInferrer) Infer(schema *Schema) {
+	for _, name := range schema.OrderedDbColumns {
+		colDef := schema.types[name]
+		if colDef.OrderIndex < len(this.columns) {
+			candidates := this.columns[colDef.OrderIndex]
+			colDef.GoType = candidates.goType()
+			colDef.Nullable = candidates.hasEmpty || !candidates.hasValues
+			if colDef.GoType == Decimal {
+				// values after sample may have more integer digits
+				colDef.Precision = MAX_INFERRED_PRECISION
+				colDef.Scale = candidates.scale
+			}
+		} else {
+			colDef.GoType = reflect.String
+			colDef.Nullable = true
+		}
+		schema.types[name] = colDef
+	}
+}
+
+func (this *columnCandidates) add(val string) {
+	if val == "" {
+		this.hasEmpty = true
+		return
+	}
+	this.hasValues = true
+
+	if this.isInt64 {
+		this.isInt8 = this.isInt8 && fitsInt(val, 8)
+		this.isInt32 = this.isInt32 && fitsInt(val, 32)
+		this.isInt64 = fitsInt(val, 64)
+	}
+	if this.isFloat64 {
+		_, err := strconv.ParseFloat(val, 64)
+		this.isFloat64 = err == nil && strings.ContainsAny(val, "0123456789")
+	}
+	if this.isDecimal {
+		intDigits, scale, ok := DecimalDigits(val)
+		this.isDecimal = ok
+		this.intDigits = max(this.intDigits, intDigits, 1)
+		this.scale = max(this.scale, scale)
+	}
+	if this.isBool {
+		// 0, 1, t and f are accepted by ParseBool but look rather like numbers or letters
+		_, err := strconv.ParseBool(val)
+		this.isBool = err == nil && len(val) > 1
+	}
+	if this.isDate {
+		_, err := dateMapper.Parse(val)
+		this.isDate = err == nil
+	}
+	if this.isTimestamp {
+		_, err := timestampMapper.Parse(val)
+		this.isTimestamp = err == nil
+	}
+	if this.isJson {
+		// scalars are valid JSON too but look rather like plain values
+		this.isJson = (val[0] == '{' || val[0] == '[') && json.Valid([]byte(val))
+	}
+}
+
+func (this *columnCandidates) goType() reflect.Kind {
+	switch {
+	case !this.hasValues:
+		return reflect.String
+	case this.isInt8:
+		return reflect.Int8
+	case this.isInt32:
+		return reflect.Int32
+	case this.isInt64:
+		return reflect.Int64
+	case this.isBool:
+		return reflect.Bool
+	case this.isDecimal && this.intDigits + this.scale <= MAX_INFERRED_PRECISION:
+		return Decimal
+	case this.isFloat64:
+		return reflect.Float64
+	case this.isDate:
+		return Date
+	case this.isTimestamp:
+		return Timestamp
+	case this.isJson:
+		return Json
+	default:
+		return reflect.String
+	}
+}
+
+func fitsInt(val string, bitSize int) bool {
+	_, err := strconv.ParseInt(val, 10, bitSize)
+	return err == nil
+}
@@ -0,0 +1,63 @@
+package common
+
+import (
+	"testing"
+	"reflect"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInferTypes(t *testing.T) {
+	schema := ParseSchema([]string{"small", "big", "flag", "price", "ratio", "day", "moment", "text", "empty"})
+
+	inferrer := NewTypeInferrer()
+	inferrer.Add([]string{"1", "3000000000", "true", "1.5", "1e-3", "2017-01-01", "2017-01-01 10:00:00", "a", ""})
+	inferrer.Add([]string{"", "-1", "False", "-12.25", "2", "2017-12-31", "2017-01-01", "1", ""})
+	inferrer.Infer(&schema)
+
+	expected := map[string]ColDef{
+		"small":{GoType:reflect.Int8, Nullable:true, OrderIndex:0},
+		"big":{GoType:reflect.Int64, Nullable:false, OrderIndex:1},
+		"flag":{GoType:reflect.Bool, Nullable:false, OrderIndex:2},
+		"price":{GoType:Decimal, Nullable:false, OrderIndex:3, Precision:MAX_INFERRED_PRECISION, Scale:2},
+		"ratio":{GoType:reflect.Float64, Nullable:false, OrderIndex:4},
+		"day":{GoType:Date, Nullable:false, OrderIndex:5},
+		"moment":{GoType:Timestamp, Nullable:false, OrderIndex:6},
+		"text":{GoType:reflect.String, Nullable:false, OrderIndex:7},
+		"empty":{GoType:reflect.String, Nullable:true, OrderIndex:8},
+	}
+	for name, colDef := range expected {
+		actual, found := schema.Get(name)
+		assert.True(t, found, name)
+		assert.Equal(t, colDef, actual, name)
+	}
+}
+
+func TestInferDecimalAfterSample(t *testing.T) {
+	schema := ParseSchema([]string{"price"})
+
+	inferrer := NewTypeInferrer()
+	inferrer.Add([]string{"9.99"})
+	inferrer.Add([]string{"1.5"})
+	inferrer.Infer(&schema)
+
+	price, _ := schema.Get("price")
+	value, err := DecimalMapper{Precision:price.Precision, Scale:price.Scale}.Apply("10.50")
+	assert.Nil(t, err)
+	assert.Equal(t, "10.50", value)
+}
+
+func TestInferJson(t *testing.T) {
+	schema := ParseSchema([]string{"doc", "list", "broken"})
+
+	inferrer := NewTypeInferrer()
+	inferrer.Add([]string{`{"a":1}`, `[1,2]`, `{"a":`})
+	inferrer.Add([]string{"", `[]`, `[]`})
+	inferrer.Infer(&schema)
+
+	doc, _ := schema.Get("doc")
+	assert.Equal(t, ColDef{GoType:Json, Nullable:true, OrderIndex:0}, doc)
+	list, _ := schema.Get("list")
+	assert.Equal(t, Json, list.GoType)
+	broken, _ := schema.Get("broken")
+	assert.Equal(t, reflect.String, broken.GoType)
+}
@@ -1,13 +1,45 @@
 package common
 
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
 type InsertColDef struct {
 	ColDef
 	ValMapper ValMapper `json:"-"`
+	// Applied to CSV value before ValMapper if set
+	Transform Transform `json:"-"`
+	// Column is filled by Value instead of CSV
+	Constant  bool
+	Value     interface{}
+	// Column is filled by CSV line number
+	LineNumber bool
+	// Column is filled by name of input file
+	FileName   bool
+}
+
+// Order index of column not present in CSV
+const NO_ORDER_INDEX = -1
+
+// Column is filled by CSV value
+func (this InsertColDef) FromCsv() bool {
+	return !this.Constant && !this.LineNumber && !this.FileName
+}
+
+// Transform and map CSV value
+func (this InsertColDef) Convert(val string) (interface{}, error) {
+	if this.Transform != nil {
+		val = this.Transform(val)
+	}
+	return this.ValMapper(val)
 }
 
 type InsertSchema struct {
 	types            map[string]InsertColDef
 	OrderedDbColumns []string
+	Upsert           Upsert
 }
 
 func (this *InsertSchema) Get(name string) (InsertColDef, bool) {
@@ -20,18 +52,82 @@ func (this *InsertSchema) Len() int {
 }
 
 func (this *InsertSchema) Add(name string, colDef ColDef) {
-	valMapper := createValMapper(colDef.GoType)
-	if colDef.Nullable {
-		valMapper = NullableMapper{Source:valMapper}.Apply
-	}
-
 	this.types[name] = InsertColDef{
-		ValMapper:valMapper,
+		ValMapper:nullable(colDef, createValMapper(colDef)),
 		ColDef:colDef,
 	}
 	this.OrderedDbColumns = append(this.OrderedDbColumns, name)
 }
 
+// Change type of CSV column keeping its transform
+func (this *InsertSchema) SetType(name string, colDef ColDef) {
+	typeDef := this.types[name]
+	colDef.OrderIndex = typeDef.OrderIndex
+	typeDef.ColDef = colDef
+	typeDef.ValMapper = nullable(colDef, createValMapper(colDef))
+	this.types[name] = typeDef
+}
+
+// Insert the same value into every row. Value is converted by column type.
+func (this *InsertSchema) AddConstant(name string, colDef ColDef, value string) error {
+	if _, found := this.types[name]; found {
+		return fmt.Errorf("Constant column %s is also mapped from CSV", name)
+	}
+	colDef.OrderIndex = NO_ORDER_INDEX
+	converted, err := nullable(colDef, createValMapper(colDef))(value)
+	if err != nil {
+		return fmt.Errorf("Can not convert constant %q of column %s: %v", value, name, err)
+	}
+	this.types[name] = InsertColDef{ColDef:colDef, Constant:true, Value:converted}
+	this.OrderedDbColumns = append(this.OrderedDbColumns, name)
+	return nil
+}
+
+// Set input formats (column name -> layout) and time zone for date and time columns
+func (this *InsertSchema) SetTimeFormats(formats map[string]string, location *time.Location) error {
+	for name := range formats {
+		typeDef, found := this.types[name]
+		if !found {
+			logrus.Warnf("Format is set for column %s missing in insert schema - ignore it", name)
+		} else if !IsTemporal(typeDef.GoType) {
+			return fmt.Errorf("Format is set for column %s of type %s. Only date and time columns have format",
+				name, TypeName(typeDef.GoType))
+		}
+	}
+	for name, typeDef := range this.types {
+		if IsTemporal(typeDef.GoType) && typeDef.FromCsv() {
+			typeDef.ValMapper = nullable(typeDef.ColDef, NewTimeMapper(typeDef.GoType, formats[name], location).Apply)
+			this.types[name] = typeDef
+		}
+	}
+	return nil
+}
+
+// Set transform chains (column name -> spec) applied before value mappers
+func (this *InsertSchema) SetTransforms(transforms map[string]string) error {
+	for name, spec := range transforms {
+		typeDef, found := this.types[name]
+		if !found || !typeDef.FromCsv() {
+			logrus.Warnf("Transform is set for column %s missing in CSV - ignore it", name)
+			continue
+		}
+		transform, err := ParseTransform(spec)
+		if err != nil {
+			return fmt.Errorf("Can not parse transform of column %s: %v", name, err)
+		}
+		typeDef.Transform = transform
+		this.types[name] = typeDef
+	}
+	return nil
+}
+
+func nullable(colDef ColDef, valMapper ValMapper) ValMapper {
+	if colDef.Nullable {
+		return NullableMapper{Source:valMapper}.Apply
+	}
+	return valMapper
+}
+
 func (this *InsertSchema) ToAsciiTable() string {
 	colDefs := make(map[string]ColDef, len(this.types))
 	for name, def := range this.types {
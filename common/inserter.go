@@ -4,31 +4,67 @@ import (
 	"database/sql"
 	"io"
 	"log"
+	"fmt"
+	"strconv"
 )
 
+const INSERT_METHOD_INSERT = "insert"
+const INSERT_METHOD_COPY = "copy"
+const INSERT_METHOD_LOAD_DATA = "load-data"
+
 type Inserter interface {
 	io.Closer
-	Add(...string) error
+	// Add row converted by PrepareInsertArguments
+	Add(...interface{}) error
+	// Stop inserting and discard rows if possible. Should be called instead of Close
+	Abort() error
+}
+
+// CSV value can not be converted to column type
+type ConversionError struct {
+	Column string
+	Value  string
+	Err    error
+}
+
+func (this ConversionError) Error() string {
+	return fmt.Sprintf("Can not convert value %q of column %s: %v", this.Value, this.Column, this.Err)
 }
 
-func PrepareInsertArguments(insertSchema InsertSchema, line []string) []interface{} {
+// Input file and line number of CSV row
+type RowPosition struct {
+	FileName string
+	Line     int
+}
+
+func PrepareInsertArguments(insertSchema InsertSchema, line []string, position RowPosition) ([]interface{}, error) {
 	result := make([]interface{}, 0, insertSchema.Len())
 	for _, name := range insertSchema.OrderedDbColumns {
 		typeDef, found := insertSchema.Get(name)
 		if !found {
 			log.Fatalf("Can not find column %s in insert schema: %v", name, insertSchema)
 		}
-		valStr := line[typeDef.OrderIndex]
-		value, err := typeDef.ValMapper(valStr)
+		if typeDef.Constant {
+			result = append(result, typeDef.Value)
+			continue
+		}
+		var valStr string
+		if typeDef.LineNumber {
+			valStr = strconv.Itoa(position.Line)
+		} else if typeDef.FileName {
+			valStr = position.FileName
+		} else {
+			valStr = line[typeDef.OrderIndex]
+		}
+		value, err := typeDef.Convert(valStr)
 		if err != nil {
-			log.Fatalf("Can not convert value %s at column %d to %v(nullable=%v)",
-				valStr, typeDef.OrderIndex, typeDef.GoType, typeDef.Nullable)
+			return nil, ConversionError{Column:name, Value:valStr, Err:err}
 		}
 		result = append(result, value)
 	}
-	return result
+	return result, nil
 }
 
 type CanPrepareStatement interface {
 	Prepare(query string) (*sql.Stmt, error)
-}
\ No newline at end of file
+}
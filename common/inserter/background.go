@@ -4,6 +4,7 @@ import (
 	"github.com/and-hom/csv2db/common"
 	"github.com/sirupsen/logrus"
 	"sync"
+	"sync/atomic"
 )
 
 const QUEUE_SIZE = 4096
@@ -11,7 +12,7 @@ const QUEUE_SIZE = 4096
 func Background(inserter *common.Inserter) common.Inserter {
 	backgroundInserter := backgroundInserter{
 		inserter:inserter,
-		dataChan:make(chan []string, QUEUE_SIZE),
+		dataChan:make(chan []interface{}, QUEUE_SIZE),
 	}
 	backgroundInserter.wg.Add(1)
 	go backgroundInserter.insertLoop()
@@ -20,37 +21,68 @@ func Background(inserter *common.Inserter) common.Inserter {
 
 type backgroundInserter struct {
 	inserter *common.Inserter
-	dataChan chan []string
+	dataChan chan []interface{}
 	wg       sync.WaitGroup
+	aborted  atomic.Bool
+	abortErr error
+	// set when insert failed. Rest rows are skipped and inserted rows are discarded
+	failed   atomic.Bool
+	err      error
+	closed   bool
 }
 
 func (this *backgroundInserter) insertLoop() {
 	defer this.wg.Done()
 	for {
-		args, ok := <-this.dataChan
+		values, ok := <-this.dataChan
 		if !ok {
 			break
 		}
-		err := (*this.inserter).Add(args...)
-		if err != nil {
-			logrus.Fatal("Can not insert: ", err)
-			return
+		if this.aborted.Load() || this.failed.Load() {
+			continue
+		}
+		if err := (*this.inserter).Add(values...); err != nil {
+			this.err = err
+			this.failed.Store(true)
 		}
 	}
-	err := (*this.inserter).Close()
-	if err != nil {
-		logrus.Fatal("Can not close inserter: ", err)
+	if this.aborted.Load() || this.failed.Load() {
+		this.abortErr = (*this.inserter).Abort()
 		return
 	}
+	this.err = (*this.inserter).Close()
 }
 
-func (this *backgroundInserter) Add(args ...string) error {
-	this.dataChan <- args
+// Error of previously queued rows is returned
+func (this *backgroundInserter) Add(values ...interface{}) error {
+	if this.failed.Load() {
+		return this.err
+	}
+	this.dataChan <- values
 	return nil
 }
 
 func (this *backgroundInserter) Close() error {
+	if this.closed {
+		return this.err
+	}
+	this.closed = true
 	close(this.dataChan)
 	this.wg.Wait()
-	return nil
-}
\ No newline at end of file
+	if this.abortErr != nil {
+		logrus.Error("Can not discard inserted rows: ", this.abortErr)
+	}
+	return this.err
+}
+
+// Queued rows are skipped
+func (this *backgroundInserter) Abort() error {
+	if this.closed {
+		return this.abortErr
+	}
+	this.closed = true
+	this.aborted.Store(true)
+	close(this.dataChan)
+	this.wg.Wait()
+	return this.abortErr
+}
@@ -11,9 +11,8 @@ type BasicInserter struct {
 	insertSchema common.InsertSchema
 }
 
-func (this BasicInserter) Add(args ...string) error {
-	objArgs := common.PrepareInsertArguments(this.insertSchema, args)
-	_, err := this.stmt.Exec(objArgs...)
+func (this BasicInserter) Add(values ...interface{}) error {
+	_, err := this.stmt.Exec(values...)
 	return err
 }
 
@@ -21,6 +20,11 @@ func (this BasicInserter) Close() error {
 	return this.stmt.Close()
 }
 
+// Rows inserted without transaction can not be discarded
+func (this BasicInserter) Abort() error {
+	return this.stmt.Close()
+}
+
 func InitBasicInserter(stmt *sql.Stmt, insertSchema common.InsertSchema) (BasicInserter, error) {
 	return BasicInserter{
 		stmt:stmt,
@@ -9,21 +9,31 @@ import (
 type bufferedTxInserter struct {
 	stmt             *sql.Stmt
 	insertSchema     common.InsertSchema
-	tx               *sql.Tx
+	tx               common.Tx
 	dbTool           common.DbTool
 	tableName        common.TableName
-	db               *sql.DB
+	db               common.Db
 	buffer           []interface{}
+	// nil if upsert is disabled
+	keys             *common.BatchKeys
 	counter          int
 	prevStmtRowCount int
 	batchSize        int
 }
 
-func (this *bufferedTxInserter) Add(args ...string) error {
-	objArgs := common.PrepareInsertArguments(this.insertSchema, args)
-	this.buffer = append(this.buffer, objArgs...)
+func (this *bufferedTxInserter) Add(values ...interface{}) error {
+	if this.keys != nil {
+		if earlier, found := this.keys.Find(values, this.counter); found {
+			row := this.buffer[earlier * len(values):]
+			for _, index := range this.keys.UpdateIndexes() {
+				row[index] = values[index]
+			}
+			return nil
+		}
+	}
+	this.buffer = append(this.buffer, values...)
 	this.counter += 1
-	if this.counter > this.batchSize {
+	if this.counter >= this.batchSize {
 		return this.flush()
 	}
 	return nil
@@ -75,6 +85,9 @@ func (this *bufferedTxInserter) flush() error {
 	_, err := this.stmt.Exec(this.buffer...)
 	this.counter = 0
 	this.buffer = this.buffer[:0]
+	if this.keys != nil {
+		this.keys.Reset()
+	}
 	return err
 }
 
@@ -100,6 +113,18 @@ func (this *bufferedTxInserter) Close() error {
 	return nil
 }
 
+func (this *bufferedTxInserter) Abort() error {
+	if this.stmt != nil {
+		if err := this.stmt.Close(); err != nil {
+			logrus.Error("Can not close statement: ", err)
+		}
+	}
+	if this.tx != nil {
+		return this.tx.Rollback()
+	}
+	return nil
+}
+
 func (this *bufferedTxInserter) closeTx(err error) error {
 	if err != nil {
 		if this.tx != nil {
@@ -110,13 +135,14 @@ func (this *bufferedTxInserter) closeTx(err error) error {
 	return nil
 }
 
-func CreateBufferedTxInserter(db *sql.DB, dbTool common.DbTool, tableName common.TableName, insertSchema common.InsertSchema, batchSize int) (common.Inserter, error) {
+func CreateBufferedTxInserter(db common.Db, dbTool common.DbTool, tableName common.TableName, insertSchema common.InsertSchema, batchSize int) (common.Inserter, error) {
 	return &bufferedTxInserter{
 		insertSchema:insertSchema,
 		db:db,
 		dbTool:dbTool,
 		tableName:tableName,
 		buffer:make([]interface{}, 0),
+		keys:common.NewBatchKeys(insertSchema),
 		counter: 0,
 		batchSize:batchSize,
 	}, nil
@@ -3,10 +3,14 @@ package inserter
 type DoNothingInserter struct {
 }
 
-func (this DoNothingInserter) Add(...string) error {
+func (this DoNothingInserter) Add(...interface{}) error {
 	return nil
 }
 
 func (this DoNothingInserter) Close() error {
 	return nil
 }
+
+func (this DoNothingInserter) Abort() error {
+	return nil
+}
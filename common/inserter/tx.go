@@ -8,10 +8,10 @@ import (
 
 type TxInserter struct {
 	BasicInserter
-	Tx *sql.Tx
+	Tx common.Tx
 }
 
-func CreateTxInserter(db *sql.DB, dbTool common.DbTool, tableName common.TableName, insertSchema common.InsertSchema) (common.Inserter, error) {
+func CreateTxInserter(db common.Db, dbTool common.DbTool, tableName common.TableName, insertSchema common.InsertSchema) (common.Inserter, error) {
 	tx, err := db.Begin()
 	if err != nil {
 		return nil, err
@@ -29,7 +29,7 @@ func CreateTxInserter(db *sql.DB, dbTool common.DbTool, tableName common.TableNa
 	}, nil
 }
 
-func InitTxInserter(stmt *sql.Stmt, insertSchema common.InsertSchema, tx *sql.Tx) (common.Inserter, error) {
+func InitTxInserter(stmt *sql.Stmt, insertSchema common.InsertSchema, tx common.Tx) (common.Inserter, error) {
 	if basic, err := InitBasicInserter(stmt, insertSchema); err!=nil {
 		return nil, err
 	} else {
@@ -41,7 +41,17 @@ func (this TxInserter) Close() error {
 	err := this.BasicInserter.Close()
 	if err != nil {
 		logrus.Error("Can not insert: ", err)
-		return this.Tx.Rollback()
+		if rollbackErr := this.Tx.Rollback(); rollbackErr != nil {
+			logrus.Error("Can not rollback: ", rollbackErr)
+		}
+		return err
 	}
 	return this.Tx.Commit()
 }
+
+func (this TxInserter) Abort() error {
+	if err := this.BasicInserter.Close(); err != nil {
+		logrus.Error("Can not close statement: ", err)
+	}
+	return this.Tx.Rollback()
+}
@@ -0,0 +1,90 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Keys and indexes of table created by csv2db
+type TableKeys struct {
+	// Auto increment column added before other columns. It is primary key if PrimaryKey is empty.
+	Identity   string
+	PrimaryKey []string
+	Unique     [][]string
+	// Secondary indexes are created by CreateIndex
+	Indexes    [][]string
+}
+
+func (this TableKeys) EffectivePrimaryKey() []string {
+	if len(this.PrimaryKey) == 0 && this.Identity != "" {
+		return []string{this.Identity}
+	}
+	return this.PrimaryKey
+}
+
+// Columns of primary key, unique constraints and indexes
+func (this TableKeys) KeyColumns() []string {
+	columns := append([]string{}, this.EffectivePrimaryKey()...)
+	for _, key := range append(append([][]string{}, this.Unique...), this.Indexes...) {
+		for _, name := range key {
+			if !containsString(columns, name) {
+				columns = append(columns, name)
+			}
+		}
+	}
+	return columns
+}
+
+// PRIMARY KEY and UNIQUE constraints of CREATE TABLE query
+func (this CommonDbTool) keyConstraints(tabSchema Schema) ([]string, error) {
+	keys := tabSchema.Keys
+	constraints := make([]string, 0)
+	if primaryKey := keys.EffectivePrimaryKey(); len(primaryKey) > 0 {
+		columns, err := this.keyColumnList(tabSchema, primaryKey)
+		if err != nil {
+			return nil, err
+		}
+		constraints = append(constraints, "PRIMARY KEY (" + columns + ")")
+	}
+	for _, key := range keys.Unique {
+		columns, err := this.keyColumnList(tabSchema, key)
+		if err != nil {
+			return nil, err
+		}
+		constraints = append(constraints, "UNIQUE (" + columns + ")")
+	}
+	return constraints, nil
+}
+
+func (this CommonDbTool) keyColumnList(tabSchema Schema, columns []string) (string, error) {
+	escapedNames := make([]string, len(columns))
+	for i, name := range columns {
+		if _, found := tabSchema.Get(name); !found && name != tabSchema.Keys.Identity {
+			return "", fmt.Errorf("Key column %s is missing in table", name)
+		}
+		escapedNames[i] = this.Escape(name)
+	}
+	return strings.Join(escapedNames, ","), nil
+}
+
+func (this CommonDbTool) CreateIndex(tableName TableName, columns []string) error {
+	return this.exec(this.CreateIndexQuery(tableName, columns))
+}
+
+func (this CommonDbTool) CreateIndexQuery(tableName TableName, columns []string) string {
+	return fmt.Sprintf("CREATE INDEX %s ON %s (%s)", this.Escape(IndexName(tableName, columns)), tableName.String(),
+		strings.Join(this.escapeAll(columns), ","))
+}
+
+// Index name is unique in schema: table_column1_column2_idx
+func IndexName(tableName TableName, columns []string) string {
+	return tableName.TablePlain + "_" + strings.Join(columns, "_") + "_idx"
+}
+
+func (this CommonDbTool) escapeAll(names []string) []string {
+	escapedNames := make([]string, len(names))
+	for i, name := range names {
+		escapedNames[i] = this.Escape(name)
+	}
+	return escapedNames
+}
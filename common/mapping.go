@@ -0,0 +1,63 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Mapping target to skip CSV column
+const SKIP_COLUMN = "-"
+
+// Rename CSV columns by mapping of CSV column name or #index to DB column name.
+// Columns mapped to SKIP_COLUMN are removed. Order indexes are kept.
+func (this *Schema) Rename(mapping map[string]string) (Schema, error) {
+	targets := make(map[int]string, len(mapping))
+	for source, target := range mapping {
+		name, found := this.columnName(source)
+		if !found {
+			return Schema{}, fmt.Errorf("Mapped column %s is missing in CSV", source)
+		}
+		index := this.types[name].OrderIndex
+		if _, duplicate := targets[index]; duplicate {
+			return Schema{}, fmt.Errorf("CSV column %s is mapped twice", source)
+		}
+		targets[index] = target
+	}
+
+	result := NewSchema()
+	for _, name := range this.OrderedDbColumns {
+		colDef := this.types[name]
+		if target, mapped := targets[colDef.OrderIndex]; mapped {
+			name = target
+		}
+		if name == SKIP_COLUMN {
+			continue
+		}
+		if _, duplicate := result.types[name]; duplicate {
+			return Schema{}, fmt.Errorf("Column %s is mapped from multiple CSV columns", name)
+		}
+		result.Add(name, colDef)
+	}
+	return result, nil
+}
+
+// Find column by name or #index
+func (this *Schema) columnName(nameOrIndex string) (string, bool) {
+	if _, found := this.types[nameOrIndex]; found {
+		return nameOrIndex, true
+	}
+	if !strings.HasPrefix(nameOrIndex, "#") {
+		return "", false
+	}
+	index, err := strconv.Atoi(nameOrIndex[1:])
+	if err != nil {
+		return "", false
+	}
+	for name, colDef := range this.types {
+		if colDef.OrderIndex == index {
+			return name, true
+		}
+	}
+	return "", false
+}
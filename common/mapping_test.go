@@ -0,0 +1,21 @@
+package common
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRename(t *testing.T) {
+	schema := ParseSchema([]string{"Item ID", "Junk", "Qty"})
+
+	renamed, err := schema.Rename(map[string]string{"Item ID":"id", "Junk":SKIP_COLUMN, "#2":"qty"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id", "qty"}, renamed.OrderedDbColumns)
+	qty, _ := renamed.Get("qty")
+	assert.Equal(t, 2, qty.OrderIndex)
+
+	_, err = schema.Rename(map[string]string{"Missing":"id"})
+	assert.Error(t, err)
+	_, err = schema.Rename(map[string]string{"Item ID":"qty", "Qty":"qty"})
+	assert.Error(t, err)
+}
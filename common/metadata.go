@@ -0,0 +1,81 @@
+package common
+
+import (
+	"crypto/rand"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Metadata column kinds
+const META_FILE = "file"
+const META_LINE = "line"
+const META_LOAD_ID = "load-id"
+const META_LOAD_TIME = "load-time"
+
+var MetadataKinds = []string{
+	META_FILE,
+	META_LINE,
+	META_LOAD_ID,
+	META_LOAD_TIME,
+}
+
+// Load context written into metadata columns
+type LoadContext struct {
+	LoadId  string
+	Started time.Time
+}
+
+func NewLoadContext() LoadContext {
+	return LoadContext{LoadId:NewUuid(), Started:time.Now()}
+}
+
+// Type of created metadata column
+func MetadataColDef(kind string) ColDef {
+	switch kind {
+	case META_LINE:
+		return ColDef{GoType:reflect.Int64, OrderIndex:NO_ORDER_INDEX}
+	case META_LOAD_TIME:
+		return ColDef{GoType:TimestampTz, OrderIndex:NO_ORDER_INDEX}
+	default:
+		return ColDef{GoType:reflect.String, OrderIndex:NO_ORDER_INDEX}
+	}
+}
+
+// Add metadata column. File name and line number are set for every row, other values are constant.
+func (this *InsertSchema) AddMetadata(name string, colDef ColDef, kind string, context LoadContext) error {
+	switch kind {
+	case META_LINE:
+		return this.addRowMetadata(name, InsertColDef{ColDef:colDef, LineNumber:true})
+	case META_FILE:
+		return this.addRowMetadata(name, InsertColDef{ColDef:colDef, FileName:true})
+	case META_LOAD_ID:
+		return this.AddConstant(name, colDef, context.LoadId)
+	case META_LOAD_TIME:
+		return this.AddConstant(name, colDef, context.Started.Format(time.RFC3339Nano))
+	default:
+		return fmt.Errorf("Unknown metadata %s of column %s", kind, name)
+	}
+}
+
+func (this *InsertSchema) addRowMetadata(name string, typeDef InsertColDef) error {
+	if _, found := this.types[name]; found {
+		return fmt.Errorf("Metadata column %s is also mapped from CSV", name)
+	}
+	typeDef.OrderIndex = NO_ORDER_INDEX
+	typeDef.ValMapper = createValMapper(typeDef.ColDef)
+	this.types[name] = typeDef
+	this.OrderedDbColumns = append(this.OrderedDbColumns, name)
+	return nil
+}
+
+// Random UUID version 4
+func NewUuid() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -8,12 +8,27 @@ import (
 	"encoding/json"
 	"github.com/olekukonko/tablewriter"
 	"fmt"
+	"sort"
 )
 
 type ColDef struct {
 	GoType     reflect.Kind
 	Nullable   bool
 	OrderIndex int
+	Precision  int `json:",omitempty"`
+	Scale      int `json:",omitempty"`
+	// Max length of varchar column. Zero means unlimited text.
+	Length     int `json:",omitempty"`
+}
+
+func (this ColDef) TypeName() string {
+	if this.Precision > 0 {
+		return fmt.Sprintf("%s(%d,%d)", TypeName(this.GoType), this.Precision, this.Scale)
+	}
+	if this.Length > 0 {
+		return fmt.Sprintf("%s(%d)", TypeName(this.GoType), this.Length)
+	}
+	return TypeName(this.GoType)
 }
 
 func NewSchema() Schema {
@@ -23,6 +38,8 @@ func NewSchema() Schema {
 type Schema struct {
 	types            map[string]ColDef
 	OrderedDbColumns []string
+	// Used by CreateTable
+	Keys             TableKeys
 }
 
 func (this *Schema) Add(name string, colDef ColDef) {
@@ -30,6 +47,11 @@ func (this *Schema) Add(name string, colDef ColDef) {
 	this.OrderedDbColumns = append(this.OrderedDbColumns, name)
 }
 
+// Replace definition of existing column
+func (this *Schema) Set(name string, colDef ColDef) {
+	this.types[name] = colDef
+}
+
 func (this *Schema) Get(name string) (ColDef, bool) {
 	typeDef, ok := this.types[name]
 	return typeDef, ok
@@ -47,6 +69,20 @@ func (this *Schema) Len() int {
 	return len(this.OrderedDbColumns)
 }
 
+// New schema with columns of all schemas
+func (this *Schema) Append(others ...Schema) Schema {
+	result := NewSchema()
+	for _, name := range this.OrderedDbColumns {
+		result.Add(name, this.types[name])
+	}
+	for _, other := range others {
+		for _, name := range other.OrderedDbColumns {
+			result.Add(name, other.types[name])
+		}
+	}
+	return result
+}
+
 func (this *Schema) ToInsertSchema() InsertSchema {
 	insertSchema := NewInsertSchema()
 	for _, name := range this.OrderedDbColumns {
@@ -55,6 +91,17 @@ func (this *Schema) ToInsertSchema() InsertSchema {
 	return insertSchema
 }
 
+// Make columns having input format date, time or timestamp columns depending on format
+func (this *Schema) ApplyTimeFormats(formats map[string]string) {
+	for name, format := range formats {
+		colDef, found := this.types[name]
+		if found && !IsTemporal(colDef.GoType) {
+			colDef.GoType = LayoutGoType(format)
+			this.types[name] = colDef
+		}
+	}
+}
+
 func (this *Schema) ToJson() string {
 	return ObjectToJson(this, true)
 }
@@ -66,40 +113,32 @@ func (this *Schema)ToAsciiTable() string {
 /// Take type and nullable from DB cchema
 func CreateCsvToDbSchemaByName(csvSchema, dbSchema Schema) InsertSchema {
 	insertSchema := NewInsertSchema()
-	for name, csvDef := range csvSchema.types {
+	for _, name := range csvSchema.OrderedDbColumns {
+		csvDef := csvSchema.types[name]
 		dbDef, found := dbSchema.types[name]
 		if !found {
 			logrus.Warnf("Can not find DB defenition for CSV column %s - use not null string type", name)
 			continue
 		}
 
-		insertSchema.Add(name, ColDef{
-			GoType: dbDef.GoType,
-			Nullable:dbDef.Nullable,
-			OrderIndex:csvDef.OrderIndex,
-		})
+		dbDef.OrderIndex = csvDef.OrderIndex
+		insertSchema.Add(name, dbDef)
 	}
 	return insertSchema
 }
 
 func CreateCsvToDbSchemaByIdx(csvSchema, dbSchema Schema) InsertSchema {
 	insertSchema := NewInsertSchema()
-	for _, csvDef := range csvSchema.types {
+	for _, csvName := range csvSchema.OrderedDbColumns {
+		csvDef := csvSchema.types[csvName]
 		name, dbDef, found := dbSchema.GetByIdx(csvDef.OrderIndex)
 		if !found {
 			logrus.Warnf("Can not find DB defenition for CSV column #%d - use not null string type", csvDef.OrderIndex)
 			continue
 		}
 
-		valMapper := createValMapper(dbDef.GoType)
-		if dbDef.Nullable {
-			valMapper = NullableMapper{Source:valMapper}.Apply
-		}
-		insertSchema.Add(name, ColDef{
-			GoType: dbDef.GoType,
-			Nullable:dbDef.Nullable,
-			OrderIndex:csvDef.OrderIndex,
-		})
+		dbDef.OrderIndex = csvDef.OrderIndex
+		insertSchema.Add(name, dbDef)
 	}
 	return insertSchema
 }
@@ -121,12 +160,27 @@ func ObjectToJson(object interface{}, pretty bool) string {
 	return buf.String()
 }
 
+// Columns are sorted by order index. Columns without order index are the last ones.
 func schemaToAsciiTable(cols map[string]ColDef) string {
-	data := make([][]string, len(cols))
-	for name, def := range cols {
-		data[def.OrderIndex] = []string{
-			name, strconv.FormatInt(int64(def.OrderIndex), 10), def.GoType.String(), strconv.FormatBool(def.Nullable),
+	names := make([]string, 0, len(cols))
+	for name := range cols {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		left, right := cols[names[i]].OrderIndex, cols[names[j]].OrderIndex
+		if left < 0 || right < 0 {
+			return left > right
+		}
+		return left < right
+	})
+	data := make([][]string, len(names))
+	for i, name := range names {
+		def := cols[name]
+		orderIndex := strconv.FormatInt(int64(def.OrderIndex), 10)
+		if def.OrderIndex == NO_ORDER_INDEX {
+			orderIndex = "-"
 		}
+		data[i] = []string{name, orderIndex, def.TypeName(), strconv.FormatBool(def.Nullable)}
 	}
 
 	buf := bytes.Buffer{}
@@ -0,0 +1,125 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"github.com/sirupsen/logrus"
+)
+
+// Makes target table rows equal to staging table rows with the same key
+type Sync struct {
+	KeyColumns       []string
+	// Columns to compare and copy. Key columns are not included
+	Columns          []string
+	// Columns copied to changed and inserted rows but not compared: constants and metadata like load id
+	SetColumns       []string
+	// Boolean column set to true instead of deleting rows. Rows are deleted if empty
+	SoftDeleteColumn string
+}
+
+// Queries run in one transaction in order: update, insert, delete
+type SyncQueries struct {
+	Update string
+	Insert string
+	Delete string
+}
+
+func (this SyncQueries) All() []string {
+	return []string{this.Update, this.Insert, this.Delete}
+}
+
+// PostgreSQL and SQLite queries: UPDATE ... FROM and IS DISTINCT FROM comparison
+func (this CommonDbTool) SyncQueries(target, staging TableName, sync Sync) SyncQueries {
+	assignments := make([]string, 0, len(sync.Columns) + len(sync.SetColumns) + 1)
+	changes := make([]string, 0, len(sync.Columns) + 1)
+	for _, name := range sync.Columns {
+		assignments = append(assignments, fmt.Sprintf("%s = s.%s", this.Escape(name), this.Escape(name)))
+		changes = append(changes, fmt.Sprintf("t.%s IS DISTINCT FROM s.%s", this.Escape(name), this.Escape(name)))
+	}
+	for _, name := range sync.SetColumns {
+		assignments = append(assignments, fmt.Sprintf("%s = s.%s", this.Escape(name), this.Escape(name)))
+	}
+	if sync.SoftDeleteColumn != "" {
+		// restore soft deleted rows
+		assignments = append(assignments, fmt.Sprintf("%s = %s", this.Escape(sync.SoftDeleteColumn), this.Literal(false)))
+		changes = append(changes, this.NullOrNotEqual("t." + this.Escape(sync.SoftDeleteColumn), false))
+	}
+	update := ""
+	// rows are not changed if only set columns differ
+	if len(changes) > 0 {
+		update = fmt.Sprintf("UPDATE %s AS t SET %s FROM %s AS s WHERE %s AND (%s)",
+			target.String(), strings.Join(assignments, ", "), staging.String(),
+			this.KeysEqual("t", "s", sync.KeyColumns), strings.Join(changes, " OR "))
+	}
+	return SyncQueries{
+		Update:update,
+		Insert:this.syncInsertQuery(target, staging, sync),
+		Delete:this.syncDeleteQuery(target, staging, sync),
+	}
+}
+
+func (this CommonDbTool) syncInsertQuery(target, staging TableName, sync Sync) string {
+	columns := make([]string, 0, len(sync.KeyColumns) + len(sync.Columns) + len(sync.SetColumns) + 1)
+	values := make([]string, 0, cap(columns))
+	for _, name := range append(append(append([]string{}, sync.KeyColumns...), sync.Columns...), sync.SetColumns...) {
+		columns = append(columns, this.Escape(name))
+		values = append(values, "s." + this.Escape(name))
+	}
+	if sync.SoftDeleteColumn != "" {
+		columns = append(columns, this.Escape(sync.SoftDeleteColumn))
+		values = append(values, this.Literal(false))
+	}
+	return fmt.Sprintf("INSERT INTO %s(%s) SELECT %s FROM %s AS s WHERE NOT EXISTS (SELECT 1 FROM %s AS t WHERE %s)",
+		target.String(), strings.Join(columns, ","), strings.Join(values, ","), staging.String(),
+		target.String(), this.KeysEqual("t", "s", sync.KeyColumns))
+}
+
+// Target table is not aliased: DELETE with alias is not supported by all databases
+func (this CommonDbTool) syncDeleteQuery(target, staging TableName, sync Sync) string {
+	missing := fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s AS s WHERE %s)",
+		staging.String(), this.KeysEqual(target.String(), "s", sync.KeyColumns))
+	if sync.SoftDeleteColumn == "" {
+		return fmt.Sprintf("DELETE FROM %s WHERE %s", target.String(), missing)
+	}
+	flag := this.Escape(sync.SoftDeleteColumn)
+	return fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s AND %s",
+		target.String(), flag, this.Literal(true), this.NullOrNotEqual(flag, true), missing)
+}
+
+func (this CommonDbTool) KeysEqual(left, right string, keyColumns []string) string {
+	conditions := make([]string, len(keyColumns))
+	for i, name := range keyColumns {
+		conditions[i] = fmt.Sprintf("%s.%s = %s.%s", left, this.Escape(name), right, this.Escape(name))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+func (this CommonDbTool) NullOrNotEqual(expr string, value interface{}) string {
+	return fmt.Sprintf("(%s IS NULL OR %s <> %s)", expr, expr, this.Literal(value))
+}
+
+// Execute queries in one transaction and return affected rows count of every query
+func (this CommonDbTool) ExecInTx(queries ...string) ([]int64, error) {
+	tx, err := this.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]int64, len(queries))
+	for i, query := range queries {
+		if query == "" {
+			continue
+		}
+		logrus.Debug(query)
+		result, err := tx.Exec(query)
+		if err == nil {
+			counts[i], err = result.RowsAffected()
+		}
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				logrus.Errorf("Can not rollback: %v", rollbackErr)
+			}
+			return nil, err
+		}
+	}
+	return counts, tx.Commit()
+}
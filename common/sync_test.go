@@ -0,0 +1,30 @@
+package common
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncQueries(t *testing.T) {
+	tool := CommonDbTool{EscapeF:func(s string) string {
+		return "\"" + s + "\""
+	}}
+	target := tool.TableName("public", "ref")
+	staging := tool.TableName("public", "ref_sync")
+
+	queries := tool.SyncQueries(target, staging, Sync{KeyColumns:[]string{"id"}, Columns:[]string{"name"}})
+	assert.Equal(t, `UPDATE "public"."ref" AS t SET "name" = s."name" FROM "public"."ref_sync" AS s WHERE t."id" = s."id" AND (t."name" IS DISTINCT FROM s."name")`, queries.Update)
+	assert.Equal(t, `INSERT INTO "public"."ref"("id","name") SELECT s."id",s."name" FROM "public"."ref_sync" AS s WHERE NOT EXISTS (SELECT 1 FROM "public"."ref" AS t WHERE t."id" = s."id")`, queries.Insert)
+	assert.Equal(t, `DELETE FROM "public"."ref" WHERE NOT EXISTS (SELECT 1 FROM "public"."ref_sync" AS s WHERE "public"."ref"."id" = s."id")`, queries.Delete)
+
+	queries = tool.SyncQueries(target, staging, Sync{KeyColumns:[]string{"id"}, Columns:[]string{"name"}, SetColumns:[]string{"load_id"}})
+	assert.Equal(t, `UPDATE "public"."ref" AS t SET "name" = s."name", "load_id" = s."load_id" FROM "public"."ref_sync" AS s WHERE t."id" = s."id" AND (t."name" IS DISTINCT FROM s."name")`, queries.Update)
+	assert.Equal(t, `INSERT INTO "public"."ref"("id","name","load_id") SELECT s."id",s."name",s."load_id" FROM "public"."ref_sync" AS s WHERE NOT EXISTS (SELECT 1 FROM "public"."ref" AS t WHERE t."id" = s."id")`, queries.Insert)
+
+	queries = tool.SyncQueries(target, staging, Sync{KeyColumns:[]string{"id"}, SetColumns:[]string{"load_id"}})
+	assert.Equal(t, "", queries.Update)
+
+	queries = tool.SyncQueries(target, staging, Sync{KeyColumns:[]string{"id"}, SoftDeleteColumn:"deleted"})
+	assert.Equal(t, `UPDATE "public"."ref" AS t SET "deleted" = FALSE FROM "public"."ref_sync" AS s WHERE t."id" = s."id" AND ((t."deleted" IS NULL OR t."deleted" <> FALSE))`, queries.Update)
+	assert.Equal(t, `UPDATE "public"."ref" SET "deleted" = TRUE WHERE ("deleted" IS NULL OR "deleted" <> TRUE) AND NOT EXISTS (SELECT 1 FROM "public"."ref_sync" AS s WHERE "public"."ref"."id" = s."id")`, queries.Delete)
+}
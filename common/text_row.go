@@ -0,0 +1,42 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Text format of PostgreSQL COPY and MySQL LOAD DATA: tab separated values
+// with backslash escaping and \N for NULL
+type TextRowFormat struct {
+	True  string
+	False string
+}
+
+var textReplacer = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`, "\x00", `\0`)
+
+func (this TextRowFormat) Format(values []interface{}) string {
+	fields := make([]string, len(values))
+	for i, value := range values {
+		fields[i] = this.FormatValue(value)
+	}
+	return strings.Join(fields, "\t") + "\n"
+}
+
+func (this TextRowFormat) FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return `\N`
+	case string:
+		return textReplacer.Replace(v)
+	case bool:
+		if v {
+			return this.True
+		}
+		return this.False
+	case time.Time:
+		return v.Format(TIMESTAMP_TZ_LAYOUT)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,122 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+const FORMAT_UNIX = "unix"
+const FORMAT_UNIX_MILLI = "unixmilli"
+
+const DATE_LAYOUT = "2006-01-02"
+const TIME_LAYOUT = "15:04:05.999999"
+const TIMESTAMP_LAYOUT = DATE_LAYOUT + " " + TIME_LAYOUT
+const TIMESTAMP_TZ_LAYOUT = TIMESTAMP_LAYOUT + "-07:00"
+
+var TIMESTAMP_LAYOUTS = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	DATE_LAYOUT,
+}
+
+var TIME_LAYOUTS = []string{
+	"15:04:05",
+	"15:04",
+}
+
+var namedLayouts = map[string]string{
+	"RFC3339":time.RFC3339,
+	"RFC3339Nano":time.RFC3339Nano,
+	"RFC1123":time.RFC1123,
+	"RFC1123Z":time.RFC1123Z,
+	"RFC822":time.RFC822,
+	"RFC822Z":time.RFC822Z,
+	"ANSIC":time.ANSIC,
+}
+
+// Parses date and time values using layouts and converts them to values accepted by database.
+// Values without zone are considered to be in Location. Values with zone are converted to Location before
+// writing into date, time and timestamp columns; timestamptz columns get the time instant itself.
+type TimeMapper struct {
+	GoType   reflect.Kind
+	Layouts  []string
+	Location *time.Location
+}
+
+// Create mapper for date/time go type. Format is go layout (02.01.2006), layout name (RFC3339) or
+// epoch format (unix, unixmilli). Empty format means ISO layouts.
+func NewTimeMapper(goType reflect.Kind, format string, location *time.Location) TimeMapper {
+	layouts := []string{format}
+	if named, found := namedLayouts[format]; found {
+		layouts = []string{named}
+	} else if format == "" {
+		if goType == Time {
+			layouts = TIME_LAYOUTS
+		} else if goType == Date {
+			layouts = []string{DATE_LAYOUT}
+		} else {
+			layouts = TIMESTAMP_LAYOUTS
+		}
+	}
+	return TimeMapper{GoType:goType, Layouts:layouts, Location:location}
+}
+
+func (this TimeMapper) Parse(val string) (time.Time, error) {
+	for _, layout := range this.Layouts {
+		switch layout {
+		case FORMAT_UNIX, FORMAT_UNIX_MILLI:
+			epoch, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				continue
+			}
+			if layout == FORMAT_UNIX {
+				return time.Unix(epoch, 0).In(this.Location), nil
+			}
+			return time.UnixMilli(epoch).In(this.Location), nil
+		default:
+			if t, err := time.ParseInLocation(layout, val, this.Location); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("Can not parse %s %s using layouts %v", TypeName(this.GoType), val, this.Layouts)
+}
+
+func (this TimeMapper) Apply(val string) (interface{}, error) {
+	t, err := this.Parse(val)
+	if err != nil {
+		return nil, err
+	}
+	switch this.GoType {
+	case Date:
+		return t.In(this.Location).Format(DATE_LAYOUT), nil
+	case Time:
+		return t.In(this.Location).Format(TIME_LAYOUT), nil
+	case Timestamp:
+		return t.In(this.Location).Format(TIMESTAMP_LAYOUT), nil
+	default:
+		return t, nil
+	}
+}
+
+// Detect column type for layout: date, time or timestamp
+func LayoutGoType(format string) reflect.Kind {
+	if format == FORMAT_UNIX || format == FORMAT_UNIX_MILLI {
+		return Timestamp
+	}
+	if named, found := namedLayouts[format]; found {
+		format = named
+	}
+	day := time.Date(2001, 2, 3, 0, 0, 0, 0, time.UTC)
+	hasTime := day.Format(format) != day.Add(13 * time.Hour + 14 * time.Minute + 15 * time.Second).Format(format)
+	hasDate := day.Format(format) != day.AddDate(1, 1, 1).Format(format)
+	if hasDate && hasTime {
+		return Timestamp
+	} else if hasTime {
+		return Time
+	}
+	return Date
+}
@@ -0,0 +1,41 @@
+package common
+
+import (
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeMapper(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3 * 60 * 60)
+
+	value, err := NewTimeMapper(Date, "02.01.2006", moscow).Apply("31.12.2017")
+	assert.Nil(t, err)
+	assert.Equal(t, "2017-12-31", value)
+
+	value, err = NewTimeMapper(Timestamp, "01/02/2006 3:04 PM", moscow).Apply("12/31/2017 1:05 PM")
+	assert.Nil(t, err)
+	assert.Equal(t, "2017-12-31 13:05:00", value)
+
+	value, err = NewTimeMapper(Timestamp, "RFC3339", moscow).Apply("2017-12-31T10:00:00Z")
+	assert.Nil(t, err)
+	assert.Equal(t, "2017-12-31 13:00:00", value)
+
+	value, err = NewTimeMapper(Timestamp, FORMAT_UNIX_MILLI, time.UTC).Apply("1514718000500")
+	assert.Nil(t, err)
+	assert.Equal(t, "2017-12-31 11:00:00.5", value)
+
+	value, err = NewTimeMapper(TimestampTz, FORMAT_UNIX, moscow).Apply("1514718000")
+	assert.Nil(t, err)
+	assert.True(t, time.Date(2017, 12, 31, 11, 0, 0, 0, time.UTC).Equal(value.(time.Time)))
+
+	_, err = NewTimeMapper(Date, "", moscow).Apply("31.12.2017")
+	assert.NotNil(t, err)
+}
+
+func TestLayoutGoType(t *testing.T) {
+	assert.Equal(t, Date, LayoutGoType("02.01.2006"))
+	assert.Equal(t, Time, LayoutGoType("15:04"))
+	assert.Equal(t, Timestamp, LayoutGoType("RFC3339"))
+	assert.Equal(t, Timestamp, LayoutGoType(FORMAT_UNIX))
+}
@@ -0,0 +1,194 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Changes CSV value before ValMapper
+type Transform func(val string) string
+
+// Transform chain spec: steps separated by |, step name and arguments separated by :
+// Use \| and \: for literal | and : in arguments.
+//	trim, upper, lower
+//	replace:REGEXP:REPLACEMENT
+//	substr:START[:LENGTH]
+//	default:VALUE				value for empty string
+//	map:FROM=TO[:FROM=TO...]	values missing in map are kept
+//	pad:left|right:LENGTH[:CHAR]
+//	strip-thousands[:SEPARATOR]	default separator is ,
+func ParseTransform(spec string) (Transform, error) {
+	steps := make([]Transform, 0)
+	for _, stepSpec := range splitUnescaped(spec, '|') {
+		parts := splitUnescaped(stepSpec, ':')
+		args := make([]string, len(parts) - 1)
+		for i, arg := range parts[1:] {
+			args[i] = argReplacer.Replace(arg)
+		}
+		step, err := parseTransformStep(strings.TrimSpace(parts[0]), args)
+		if err != nil {
+			return nil, fmt.Errorf("Bad transform %s: %v", stepSpec, err)
+		}
+		steps = append(steps, step)
+	}
+	return func(val string) string {
+		for _, step := range steps {
+			val = step(val)
+		}
+		return val
+	}, nil
+}
+
+var argReplacer = strings.NewReplacer(`\|`, "|", `\:`, ":")
+
+func parseTransformStep(name string, args []string) (Transform, error) {
+	switch name {
+	case "trim":
+		return strings.TrimSpace, expectArgs(args, 0, 0)
+	case "upper":
+		return strings.ToUpper, expectArgs(args, 0, 0)
+	case "lower":
+		return strings.ToLower, expectArgs(args, 0, 0)
+	case "replace":
+		if err := expectArgs(args, 2, 2); err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return func(val string) string {
+			return re.ReplaceAllString(val, args[1])
+		}, nil
+	case "substr":
+		return substrTransform(args)
+	case "default":
+		return func(val string) string {
+			if val == "" {
+				return args[0]
+			}
+			return val
+		}, expectArgs(args, 1, 1)
+	case "map":
+		return mapTransform(args)
+	case "pad":
+		return padTransform(args)
+	case "strip-thousands":
+		separator := ","
+		if len(args) > 0 {
+			separator = args[0]
+		}
+		return func(val string) string {
+			return strings.Replace(val, separator, "", -1)
+		}, expectArgs(args, 0, 1)
+	default:
+		return nil, fmt.Errorf("Unknown transform %s", name)
+	}
+}
+
+// START and LENGTH are counted in characters. Values shorter than START become empty.
+func substrTransform(args []string) (Transform, error) {
+	if err := expectArgs(args, 1, 2); err != nil {
+		return nil, err
+	}
+	start, err := strconv.Atoi(args[0])
+	if err != nil || start < 0 {
+		return nil, fmt.Errorf("Start should be non-negative integer: %s", args[0])
+	}
+	length := -1
+	if len(args) > 1 {
+		if length, err = strconv.Atoi(args[1]); err != nil || length < 0 {
+			return nil, fmt.Errorf("Length should be non-negative integer: %s", args[1])
+		}
+	}
+	return func(val string) string {
+		runes := []rune(val)
+		if start >= len(runes) {
+			return ""
+		}
+		runes = runes[start:]
+		if length >= 0 && length < len(runes) {
+			runes = runes[:length]
+		}
+		return string(runes)
+	}, nil
+}
+
+func mapTransform(args []string) (Transform, error) {
+	if err := expectArgs(args, 1, -1); err != nil {
+		return nil, err
+	}
+	mapping := make(map[string]string, len(args))
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) < 2 {
+			return nil, fmt.Errorf("Map entry should look like from=to: %s", arg)
+		}
+		mapping[kv[0]] = kv[1]
+	}
+	return func(val string) string {
+		if mapped, found := mapping[val]; found {
+			return mapped
+		}
+		return val
+	}, nil
+}
+
+func padTransform(args []string) (Transform, error) {
+	if err := expectArgs(args, 2, 3); err != nil {
+		return nil, err
+	}
+	left := args[0] == "left"
+	if !left && args[0] != "right" {
+		return nil, fmt.Errorf("Pad side should be left or right: %s", args[0])
+	}
+	length, err := strconv.Atoi(args[1])
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("Length should be non-negative integer: %s", args[1])
+	}
+	padChar := " "
+	if len(args) > 2 {
+		if utf8.RuneCountInString(args[2]) != 1 {
+			return nil, fmt.Errorf("Pad should be a single char: %s", args[2])
+		}
+		padChar = args[2]
+	}
+	return func(val string) string {
+		missing := length - utf8.RuneCountInString(val)
+		if missing <= 0 {
+			return val
+		}
+		if left {
+			return strings.Repeat(padChar, missing) + val
+		}
+		return val + strings.Repeat(padChar, missing)
+	}, nil
+}
+
+// max < 0 means any count
+func expectArgs(args []string, min, max int) error {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		return fmt.Errorf("Unexpected arguments count %d", len(args))
+	}
+	return nil
+}
+
+// Split by separator not escaped by backslash. Escapes are kept.
+func splitUnescaped(s string, separator rune) []string {
+	result := make([]string, 0)
+	current := strings.Builder{}
+	escaped := false
+	for _, r := range s {
+		if r == separator && !escaped {
+			result = append(result, current.String())
+			current.Reset()
+			continue
+		}
+		escaped = r == '\\' && !escaped
+		current.WriteRune(r)
+	}
+	return append(result, current.String())
+}
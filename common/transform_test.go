@@ -0,0 +1,32 @@
+package common
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransform(t *testing.T) {
+	cases := map[string][][]string{
+		"trim|upper":{{" abc ", "ABC"}},
+		`replace:\s+: `:{{"a  b\tc", "a b c"}},
+		`replace:(\d+)\:(\d+):$2\:$1`:{{"10:20", "20:10"}},
+		"substr:1:2":{{"abcd", "bc"}, {"a", ""}},
+		"default:N/A":{{"", "N/A"}, {"x", "x"}},
+		"map:Y=true:N=false":{{"Y", "true"}, {"N", "false"}, {"?", "?"}},
+		"pad:left:5:0":{{"42", "00042"}, {"123456", "123456"}},
+		"strip-thousands":{{"1,234,567.89", "1234567.89"}},
+		`strip-thousands:.|replace:,:.`:{{"1.234,5", "1234.5"}},
+	}
+	for spec, values := range cases {
+		transform, err := ParseTransform(spec)
+		assert.Nil(t, err, spec)
+		for _, value := range values {
+			assert.Equal(t, value[1], transform(value[0]), spec)
+		}
+	}
+
+	for _, spec := range []string{"unknown", "substr:x", "pad:middle:3", "replace:(:x", "trim:1"} {
+		_, err := ParseTransform(spec)
+		assert.Error(t, err, spec)
+	}
+}
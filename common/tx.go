@@ -0,0 +1,97 @@
+package common
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/sirupsen/logrus"
+)
+
+// Database connection used by DbTool and inserters. It is either *sql.DB or one transaction for all queries.
+type Db interface {
+	CanPrepareStatement
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Begin() (Tx, error)
+}
+
+type Tx interface {
+	CanPrepareStatement
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Commit() error
+	Rollback() error
+}
+
+func WrapDb(db *sql.DB) Db {
+	return sqlDb{DB:db}
+}
+
+type sqlDb struct {
+	*sql.DB
+}
+
+func (this sqlDb) Begin() (Tx, error) {
+	tx, err := this.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// Runs all queries in one transaction which should be rolled back finally.
+// Transactions started by Begin are savepoints of it.
+func BeginRollbackDb(db *sql.DB) (*RollbackDb, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &RollbackDb{Tx:tx}, nil
+}
+
+type RollbackDb struct {
+	*sql.Tx
+	savepoints int
+}
+
+func (this *RollbackDb) Begin() (Tx, error) {
+	this.savepoints++
+	name := fmt.Sprintf("csv2db_%d", this.savepoints)
+	logrus.Debug("SAVEPOINT " + name)
+	if _, err := this.Tx.Exec("SAVEPOINT " + name); err != nil {
+		return nil, err
+	}
+	return &savepoint{tx:this.Tx, name:name}, nil
+}
+
+type savepoint struct {
+	tx   *sql.Tx
+	name string
+	done bool
+}
+
+func (this *savepoint) Prepare(query string) (*sql.Stmt, error) {
+	return this.tx.Prepare(query)
+}
+
+func (this *savepoint) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return this.tx.Exec(query, args...)
+}
+
+func (this *savepoint) Commit() error {
+	return this.finish("RELEASE SAVEPOINT ")
+}
+
+// Changes made after savepoint are discarded. Enclosing transaction can be used further.
+func (this *savepoint) Rollback() error {
+	return this.finish("ROLLBACK TO SAVEPOINT ")
+}
+
+func (this *savepoint) finish(command string) error {
+	if this.done {
+		return sql.ErrTxDone
+	}
+	this.done = true
+	logrus.Debug(command + this.name)
+	_, err := this.tx.Exec(command + this.name)
+	return err
+}
@@ -0,0 +1,110 @@
+package common_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/and-hom/csv2db/common"
+	"github.com/and-hom/csv2db/_mysql"
+)
+
+// Like MySQL connection, it can not run query while rows of previous one are not closed
+type busyConn struct {
+	openRows int
+	queries  []string
+}
+
+func (this *busyConn) Prepare(query string) (driver.Stmt, error) {
+	if this.openRows > 0 {
+		return nil, errors.New("busy buffer")
+	}
+	this.queries = append(this.queries, query)
+	return busyStmt{conn:this}, nil
+}
+
+func (this *busyConn) Close() error {
+	return nil
+}
+
+func (this *busyConn) Begin() (driver.Tx, error) {
+	return busyTx{}, nil
+}
+
+type busyTx struct{}
+
+func (busyTx) Commit() error {
+	return nil
+}
+
+func (busyTx) Rollback() error {
+	return nil
+}
+
+type busyStmt struct {
+	conn *busyConn
+}
+
+func (this busyStmt) Close() error {
+	return nil
+}
+
+func (this busyStmt) NumInput() int {
+	return -1
+}
+
+func (this busyStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+func (this busyStmt) Query(args []driver.Value) (driver.Rows, error) {
+	this.conn.openRows++
+	return &busyRows{conn:this.conn}, nil
+}
+
+type busyRows struct {
+	conn *busyConn
+	done bool
+}
+
+func (this *busyRows) Columns() []string {
+	return []string{"DATABASE()"}
+}
+
+func (this *busyRows) Close() error {
+	this.conn.openRows--
+	return nil
+}
+
+func (this *busyRows) Next(dest []driver.Value) error {
+	if this.done {
+		return io.EOF
+	}
+	this.done = true
+	dest[0] = "csv2db"
+	return nil
+}
+
+type busyDriver struct {
+	conn *busyConn
+}
+
+func (this busyDriver) Open(name string) (driver.Conn, error) {
+	return this.conn, nil
+}
+
+func TestMysqlToolInRollbackDb(t *testing.T) {
+	conn := &busyConn{}
+	sql.Register("csv2db_busy", busyDriver{conn:conn})
+	db, _ := sql.Open("csv2db_busy", "")
+	rollbackDb, err := common.BeginRollbackDb(db)
+	assert.Nil(t, err)
+	defer rollbackDb.Rollback()
+
+	tool := _mysql.MakeDbTool(rollbackDb, common.INSERT_METHOD_INSERT)
+	tableName := tool.TableName("", "items")
+	assert.Nil(t, tool.DropTable(tableName))
+	assert.Equal(t, []string{"SELECT DATABASE()", "DROP TABLE `csv2db`.`items`"}, conn.queries)
+}
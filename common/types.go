@@ -0,0 +1,114 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"strconv"
+)
+
+// Column types that have no own reflect.Kind. Values are placed after the last
+// reflect kind so they can share type mappings with the plain go kinds.
+const (
+	Date reflect.Kind = iota + reflect.UnsafePointer + 1
+	Timestamp
+	Time
+	TimestampTz
+	Decimal
+	// JSON text. Nested objects and arrays of JSON input are loaded as JSON
+	Json
+)
+
+var extraTypeNames = map[reflect.Kind]string{
+	Date:"date",
+	Timestamp:"timestamp",
+	Time:"time",
+	TimestampTz:"timestamptz",
+	Decimal:"decimal",
+	Json:"json",
+}
+
+func TypeName(goType reflect.Kind) string {
+	if name, found := extraTypeNames[goType]; found {
+		return name
+	}
+	return goType.String()
+}
+
+// Type names accepted by ParseColDef
+var declaredTypes = map[string]reflect.Kind{
+	"string":reflect.String,
+	"text":reflect.String,
+	"varchar":reflect.String,
+	"int8":reflect.Int8,
+	"int":reflect.Int32,
+	"integer":reflect.Int32,
+	"int32":reflect.Int32,
+	"bigint":reflect.Int64,
+	"int64":reflect.Int64,
+	"float":reflect.Float64,
+	"double":reflect.Float64,
+	"float64":reflect.Float64,
+	"real":reflect.Float32,
+	"float32":reflect.Float32,
+	"bool":reflect.Bool,
+	"boolean":reflect.Bool,
+	"decimal":Decimal,
+	"numeric":Decimal,
+	"date":Date,
+	"time":Time,
+	"timestamp":Timestamp,
+	"timestamptz":TimestampTz,
+	"json":Json,
+}
+
+// Parse column type declared by user: int, bigint, decimal(10,2), varchar(20), date...
+func ParseColDef(typeName string) (ColDef, error) {
+	goType, found := declaredTypes[strings.ToLower(BaseTypeName(typeName))]
+	if !found {
+		return ColDef{}, fmt.Errorf("Unknown column type %s", typeName)
+	}
+	colDef := ColDef{GoType:goType}
+	modifiers := TypeModifiers(typeName)
+	switch {
+	case goType == Decimal && len(modifiers) > 0:
+		colDef.Precision = modifiers[0]
+		if len(modifiers) > 1 {
+			colDef.Scale = modifiers[1]
+		}
+	case goType == reflect.String && len(modifiers) > 0:
+		colDef.Length = modifiers[0]
+	case len(modifiers) > 0:
+		return ColDef{}, fmt.Errorf("Column type %s has no length or precision", typeName)
+	}
+	return colDef, nil
+}
+
+func IsTemporal(goType reflect.Kind) bool {
+	return goType == Date || goType == Time || goType == Timestamp || goType == TimestampTz
+}
+
+var typeModifiersRegexp = regexp.MustCompile(`\s*\([^)]*\)`)
+
+// Remove length, precision and scale from DB type name: timestamp(3) without time zone -> timestamp without time zone
+func BaseTypeName(dbType string) string {
+	return strings.TrimSpace(typeModifiersRegexp.ReplaceAllString(dbType, ""))
+}
+
+// Parse length, precision and scale of DB type: numeric(10,2) -> [10, 2]
+func TypeModifiers(dbType string) []int {
+	modifiers := make([]int, 0)
+	found := typeModifiersRegexp.FindString(dbType)
+	if found == "" {
+		return modifiers
+	}
+	for _, modifier := range strings.Split(strings.Trim(strings.TrimSpace(found), "()"), ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(modifier))
+		if err != nil {
+			return modifiers
+		}
+		modifiers = append(modifiers, value)
+	}
+	return modifiers
+}
@@ -0,0 +1,161 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+const ON_CONFLICT_UPDATE_ALL = "update-all"
+const ON_CONFLICT_UPDATE_SELECTED = "update-selected"
+const ON_CONFLICT_SKIP = "skip"
+
+var OnConflictPolicies = []string{
+	ON_CONFLICT_UPDATE_ALL,
+	ON_CONFLICT_UPDATE_SELECTED,
+	ON_CONFLICT_SKIP,
+}
+
+// Conflict resolution of inserted rows. Zero value means plain insert.
+type Upsert struct {
+	KeyColumns    []string
+	// Columns to update for update-selected policy
+	UpdateColumns []string
+	OnConflict    string
+}
+
+func (this Upsert) Enabled() bool {
+	return len(this.KeyColumns) > 0
+}
+
+// Columns to update on conflict. Empty for skip policy.
+func (this Upsert) ColumnsToUpdate(insertSchema InsertSchema) []string {
+	switch this.OnConflict {
+	case ON_CONFLICT_SKIP:
+		return []string{}
+	case ON_CONFLICT_UPDATE_SELECTED:
+		return this.UpdateColumns
+	}
+	result := make([]string, 0, len(insertSchema.OrderedDbColumns))
+	for _, name := range insertSchema.OrderedDbColumns {
+		if !containsString(this.KeyColumns, name) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// Key and update columns should be inserted, update columns should not be keys
+func (this Upsert) Validate(insertSchema InsertSchema) error {
+	if this.OnConflict == ON_CONFLICT_UPDATE_SELECTED && len(this.UpdateColumns) == 0 {
+		return fmt.Errorf("Columns to update should be set for %s policy", ON_CONFLICT_UPDATE_SELECTED)
+	}
+	if this.OnConflict != ON_CONFLICT_UPDATE_SELECTED && len(this.UpdateColumns) > 0 {
+		return fmt.Errorf("Columns to update can be set for %s policy only", ON_CONFLICT_UPDATE_SELECTED)
+	}
+	for _, name := range this.KeyColumns {
+		if _, found := insertSchema.Get(name); !found {
+			return fmt.Errorf("Key column %s is missing in insert schema", name)
+		}
+	}
+	for _, name := range this.ColumnsToUpdate(insertSchema) {
+		if _, found := insertSchema.Get(name); !found {
+			return fmt.Errorf("Update column %s is missing in insert schema", name)
+		}
+		if containsString(this.KeyColumns, name) {
+			return fmt.Errorf("Key column %s can not be updated", name)
+		}
+	}
+	return nil
+}
+
+// Keys of rows in one multi-row upsert statement. ON CONFLICT DO UPDATE can not change a row twice in one
+// statement, so row with key already present in batch should be merged into the earlier one: its update
+// columns are copied like the row was upserted after it. Rows with null keys do not conflict.
+type BatchKeys struct {
+	keyIndexes    []int
+	updateIndexes []int
+	rows          map[string]int
+}
+
+// Nil if upsert is disabled
+func NewBatchKeys(insertSchema InsertSchema) *BatchKeys {
+	if !insertSchema.Upsert.Enabled() {
+		return nil
+	}
+	indexes := make(map[string]int, len(insertSchema.OrderedDbColumns))
+	for i, name := range insertSchema.OrderedDbColumns {
+		indexes[name] = i
+	}
+	batchKeys := BatchKeys{rows:make(map[string]int)}
+	for _, name := range insertSchema.Upsert.KeyColumns {
+		batchKeys.keyIndexes = append(batchKeys.keyIndexes, indexes[name])
+	}
+	for _, name := range insertSchema.Upsert.ColumnsToUpdate(insertSchema) {
+		batchKeys.updateIndexes = append(batchKeys.updateIndexes, indexes[name])
+	}
+	return &batchKeys
+}
+
+// Index of earlier row with the same key. Otherwise row is remembered by its index in batch.
+func (this *BatchKeys) Find(values []interface{}, row int) (int, bool) {
+	keyValues := make([]interface{}, len(this.keyIndexes))
+	for i, index := range this.keyIndexes {
+		if values[index] == nil {
+			return 0, false
+		}
+		keyValues[i] = values[index]
+	}
+	key := fmt.Sprintf("%#v", keyValues)
+	if earlier, found := this.rows[key]; found {
+		return earlier, true
+	}
+	this.rows[key] = row
+	return 0, false
+}
+
+// Indexes of values copied into earlier row with the same key
+func (this *BatchKeys) UpdateIndexes() []int {
+	return this.updateIndexes
+}
+
+func (this *BatchKeys) Reset() {
+	this.rows = make(map[string]int)
+}
+
+// Conflict resolution clause appended to INSERT query. Empty if upsert is disabled.
+func (this CommonDbTool) UpsertClause(insertSchema InsertSchema) string {
+	if !insertSchema.Upsert.Enabled() {
+		return ""
+	}
+	if this.UpsertClauseF != nil {
+		return this.UpsertClauseF(insertSchema)
+	}
+	return this.OnConflictClause(insertSchema)
+}
+
+// ON CONFLICT clause of PostgreSQL and SQLite
+func (this CommonDbTool) OnConflictClause(insertSchema InsertSchema) string {
+	upsert := insertSchema.Upsert
+	keys := make([]string, len(upsert.KeyColumns))
+	for i, name := range upsert.KeyColumns {
+		keys[i] = this.Escape(name)
+	}
+	updateColumns := upsert.ColumnsToUpdate(insertSchema)
+	if len(updateColumns) == 0 {
+		return fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", strings.Join(keys, ","))
+	}
+	assignments := make([]string, len(updateColumns))
+	for i, name := range updateColumns {
+		assignments[i] = fmt.Sprintf("%s = EXCLUDED.%s", this.Escape(name), this.Escape(name))
+	}
+	return fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(keys, ","), strings.Join(assignments, ", "))
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
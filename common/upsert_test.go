@@ -0,0 +1,61 @@
+package common
+
+import (
+	"testing"
+	"reflect"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpsertClause(t *testing.T) {
+	tool := CommonDbTool{EscapeF:func(s string) string {
+		return "\"" + s + "\""
+	}}
+	insertSchema := NewInsertSchema()
+	insertSchema.Add("id", ColDef{GoType:reflect.Int64})
+	insertSchema.Add("name", ColDef{GoType:reflect.String})
+	insertSchema.Add("qty", ColDef{GoType:reflect.Int32})
+	assert.Equal(t, "", tool.UpsertClause(insertSchema))
+
+	insertSchema.Upsert = Upsert{KeyColumns:[]string{"id"}, OnConflict:ON_CONFLICT_UPDATE_ALL}
+	assert.Equal(t, ` ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name", "qty" = EXCLUDED."qty"`, tool.UpsertClause(insertSchema))
+
+	insertSchema.Upsert = Upsert{KeyColumns:[]string{"id"}, UpdateColumns:[]string{"qty"}, OnConflict:ON_CONFLICT_UPDATE_SELECTED}
+	assert.Equal(t, ` ON CONFLICT ("id") DO UPDATE SET "qty" = EXCLUDED."qty"`, tool.UpsertClause(insertSchema))
+
+	insertSchema.Upsert = Upsert{KeyColumns:[]string{"id", "name"}, OnConflict:ON_CONFLICT_SKIP}
+	assert.Equal(t, ` ON CONFLICT ("id","name") DO NOTHING`, tool.UpsertClause(insertSchema))
+
+	insertSchema.Upsert = Upsert{KeyColumns:[]string{"id"}, UpdateColumns:[]string{"id"}, OnConflict:ON_CONFLICT_UPDATE_SELECTED}
+	assert.Error(t, insertSchema.Upsert.Validate(insertSchema))
+	insertSchema.Upsert = Upsert{KeyColumns:[]string{"missing"}, OnConflict:ON_CONFLICT_UPDATE_ALL}
+	assert.Error(t, insertSchema.Upsert.Validate(insertSchema))
+	insertSchema.Upsert = Upsert{KeyColumns:[]string{"id"}, UpdateColumns:[]string{"qty"}, OnConflict:ON_CONFLICT_UPDATE_ALL}
+	assert.Error(t, insertSchema.Upsert.Validate(insertSchema))
+}
+
+func TestBatchKeys(t *testing.T) {
+	insertSchema := NewInsertSchema()
+	insertSchema.Add("id", ColDef{GoType:reflect.Int64})
+	insertSchema.Add("name", ColDef{GoType:reflect.String})
+	insertSchema.Add("qty", ColDef{GoType:reflect.Int32})
+	assert.Nil(t, NewBatchKeys(insertSchema))
+
+	insertSchema.Upsert = Upsert{KeyColumns:[]string{"id"}, UpdateColumns:[]string{"qty"}, OnConflict:ON_CONFLICT_UPDATE_SELECTED}
+	keys := NewBatchKeys(insertSchema)
+	assert.Equal(t, []int{2}, keys.UpdateIndexes())
+	_, found := keys.Find([]interface{}{int64(1), "a", int32(1)}, 0)
+	assert.False(t, found)
+	_, found = keys.Find([]interface{}{int64(2), "b", int32(2)}, 1)
+	assert.False(t, found)
+	earlier, found := keys.Find([]interface{}{int64(1), "c", int32(3)}, 2)
+	assert.True(t, found)
+	assert.Equal(t, 0, earlier)
+	_, found = keys.Find([]interface{}{nil, "d", int32(4)}, 2)
+	assert.False(t, found)
+	_, found = keys.Find([]interface{}{nil, "e", int32(5)}, 3)
+	assert.False(t, found)
+
+	keys.Reset()
+	_, found = keys.Find([]interface{}{int64(1), "a", int32(1)}, 0)
+	assert.False(t, found)
+}
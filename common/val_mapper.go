@@ -4,14 +4,19 @@ import (
 	"strconv"
 	"reflect"
 	"github.com/sirupsen/logrus"
+	"time"
+	"encoding/json"
+	"errors"
 )
 
-func createValMapper(goType reflect.Kind) ValMapper {
-	switch goType {
+func createValMapper(colDef ColDef) ValMapper {
+	switch goType := colDef.GoType; goType {
 	case reflect.Int64:
 		return Int64ValMapper
 	case reflect.Int32:
 		return Int32ValMapper
+	case reflect.Int16:
+		return Int16ValMapper
 	case reflect.Int8:
 		return Int8ValMapper
 	case reflect.Float64:
@@ -22,8 +27,14 @@ func createValMapper(goType reflect.Kind) ValMapper {
 		return StringValMapper
 	case reflect.Bool:
 		return BoolValMapper
+	case Date, Time, Timestamp, TimestampTz:
+		return NewTimeMapper(goType, "", time.Local).Apply
+	case Decimal:
+		return DecimalMapper{Precision:colDef.Precision, Scale:colDef.Scale}.Apply
+	case Json:
+		return JsonValMapper
 	default:
-		logrus.Fatalf("Unsupported go type %v - can not create value mapper", goType)
+		logrus.Fatalf("Unsupported go type %s - can not create value mapper", TypeName(goType))
 		return nil
 	}
 }
@@ -53,6 +64,10 @@ func Int32ValMapper(val string) (interface{}, error) {
 	return strconv.ParseInt(val, 10, 32)
 }
 
+func Int16ValMapper(val string) (interface{}, error) {
+	return strconv.ParseInt(val, 10, 16)
+}
+
 func Int8ValMapper(val string) (interface{}, error) {
 	return strconv.ParseInt(val, 10, 8)
 }
@@ -69,3 +84,10 @@ func BoolValMapper(val string) (interface{}, error) {
 	return strconv.ParseBool(val)
 }
 
+// JSON is checked and passed as text
+func JsonValMapper(val string) (interface{}, error) {
+	if !json.Valid([]byte(val)) {
+		return nil, errors.New("Invalid JSON")
+	}
+	return val, nil
+}
@@ -0,0 +1,115 @@
+package common
+
+import (
+	"reflect"
+	"unicode/utf8"
+	"github.com/sirupsen/logrus"
+)
+
+// Digits needed by decimal column to hold any value of integer column
+var intDigits = map[reflect.Kind]int{
+	reflect.Int8:5,
+	reflect.Int16:5,
+	reflect.Int32:10,
+	reflect.Int64:19,
+}
+
+// Collects CSV values of existing table columns and finds wider types for columns that can not hold them:
+// int -> bigint -> decimal -> text, decimal -> decimal with more digits -> text, varchar(n) -> varchar(max length).
+// Other columns are not changed, so their misfit values are rejected by loading.
+type TypeWidener struct {
+	columns []*widenedColumn
+}
+
+type widenedColumn struct {
+	name       string
+	colDef     InsertColDef
+	candidates columnCandidates
+	// some value can not be converted to column type
+	misfit     bool
+	maxLength  int
+}
+
+// CSV columns of insert schema are checked. Transforms and formats should be set already.
+func NewTypeWidener(insertSchema InsertSchema) *TypeWidener {
+	widener := TypeWidener{}
+	for _, name := range insertSchema.OrderedDbColumns {
+		colDef := insertSchema.types[name]
+		if colDef.OrderIndex == NO_ORDER_INDEX || !colDef.FromCsv() {
+			continue
+		}
+		widener.columns = append(widener.columns, &widenedColumn{
+			name:name,
+			colDef:colDef,
+			candidates:newColumnCandidates(),
+		})
+	}
+	return &widener
+}
+
+func (this *TypeWidener) Add(line []string) {
+	for _, column := range this.columns {
+		val := ""
+		if column.colDef.OrderIndex < len(line) {
+			val = line[column.colDef.OrderIndex]
+		}
+		if column.colDef.Transform != nil {
+			val = column.colDef.Transform(val)
+		}
+		// empty value of not null column is a null constraint violation that is not fixed by wider type
+		if val == "" {
+			continue
+		}
+		if _, err := column.colDef.ValMapper(val); err != nil {
+			column.misfit = true
+		}
+		column.candidates.add(val)
+		column.maxLength = max(column.maxLength, utf8.RuneCountInString(val))
+	}
+}
+
+// Wider definitions of columns which can not hold all values
+func (this *TypeWidener) Widened() Schema {
+	widened := NewSchema()
+	for _, column := range this.columns {
+		colDef := column.colDef.ColDef
+		var wider ColDef
+		if column.misfit {
+			var found bool
+			if wider, found = widerType(colDef, column.candidates); !found {
+				logrus.Warnf("Some values of column %s do not fit type %s and can not be widened", column.name, colDef.TypeName())
+				continue
+			}
+		} else if colDef.GoType == reflect.String && colDef.Length > 0 && column.maxLength > colDef.Length {
+			wider = colDef
+			wider.Length = column.maxLength
+		} else {
+			continue
+		}
+		widened.Add(column.name, wider)
+	}
+	return widened
+}
+
+// Narrowest type wider than numeric column type that fits all collected values.
+// Text is the last resort for numeric columns. False if column is not numeric.
+func widerType(colDef ColDef, candidates columnCandidates) (ColDef, bool) {
+	digits, isInt := intDigits[colDef.GoType]
+	if !isInt && colDef.GoType != Decimal {
+		return ColDef{}, false
+	}
+	wider := ColDef{GoType:reflect.String, Nullable:colDef.Nullable, OrderIndex:colDef.OrderIndex}
+	scale := max(candidates.scale, colDef.Scale)
+	integer := max(candidates.intDigits, digits, colDef.Precision - colDef.Scale)
+	switch {
+	case isInt && candidates.isInt32 && digits < intDigits[reflect.Int32]:
+		wider.GoType = reflect.Int32
+	case isInt && candidates.isInt64 && digits < intDigits[reflect.Int64]:
+		wider.GoType = reflect.Int64
+	case candidates.isDecimal && integer + scale <= MAX_INFERRED_PRECISION:
+		wider.GoType = Decimal
+		wider.Precision = integer + scale
+		wider.Scale = scale
+	}
+	return wider, true
+}
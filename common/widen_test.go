@@ -0,0 +1,37 @@
+package common
+
+import (
+	"testing"
+	"reflect"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWidenTypes(t *testing.T) {
+	insertSchema := NewInsertSchema()
+	insertSchema.Add("small", ColDef{GoType:reflect.Int8, OrderIndex:0})
+	insertSchema.Add("count", ColDef{GoType:reflect.Int32, OrderIndex:1})
+	insertSchema.Add("price", ColDef{GoType:Decimal, Precision:5, Scale:2, OrderIndex:2})
+	insertSchema.Add("code", ColDef{GoType:reflect.String, Length:3, OrderIndex:3})
+	insertSchema.Add("day", ColDef{GoType:Date, Nullable:true, OrderIndex:4})
+	insertSchema.Add("fits", ColDef{GoType:reflect.Int64, OrderIndex:5})
+	insertSchema.Add("qty", ColDef{GoType:reflect.Int32, Nullable:true, OrderIndex:6})
+
+	widener := NewTypeWidener(insertSchema)
+	widener.Add([]string{"1000", "3000000000", "123.456", "abcd", "", "1", "5"})
+	widener.Add([]string{"1", "1.5", "1", "ab", "soon", "2", "n/a"})
+	widened := widener.Widened()
+
+	// date column is not changed, so row with bad date is rejected later
+	assert.Equal(t, []string{"small", "count", "price", "code", "qty"}, widened.OrderedDbColumns)
+	expected := map[string]ColDef{
+		"small":{GoType:reflect.Int32, OrderIndex:0},
+		"count":{GoType:Decimal, Precision:11, Scale:1, OrderIndex:1},
+		"price":{GoType:Decimal, Precision:6, Scale:3, OrderIndex:2},
+		"code":{GoType:reflect.String, Length:4, OrderIndex:3},
+		"qty":{GoType:reflect.String, Nullable:true, OrderIndex:6},
+	}
+	for name, colDef := range expected {
+		actual, _ := widened.Get(name)
+		assert.Equal(t, colDef, actual, name)
+	}
+}
@@ -10,16 +10,28 @@ import (
 	"io/ioutil"
 	"reflect"
 	"strings"
+	"time"
+	"strconv"
+	"fmt"
 )
 
 const postgres = "postgres"
 const mysql = "mysql"
+const sqlite = "sqlite3"
 
 const MODE_CREATE = "create"
 const MODE_DELETE_ALL = "delete-all"
 const MODE_TRUNCATE = "truncate"
 const MODE_DROP_AND_CREATE = "drop-and-create"
 const MODE_TABLE_AS_IS = "as-is"
+const MODE_UPSERT = "upsert"
+const MODE_SYNC = "sync"
+
+var insertMethods = []string{
+	common.INSERT_METHOD_INSERT,
+	common.INSERT_METHOD_COPY,
+	common.INSERT_METHOD_LOAD_DATA,
+}
 
 var modes = []string{
 	MODE_CREATE,
@@ -27,6 +39,8 @@ var modes = []string{
 	MODE_TRUNCATE,
 	MODE_DROP_AND_CREATE,
 	MODE_TABLE_AS_IS,
+	MODE_UPSERT,
+	MODE_SYNC,
 }
 
 type Config struct {
@@ -36,10 +50,73 @@ type Config struct {
 	Table        string
 	TableMode    TableMode
 
-	FileName     string
+	// Files, glob patterns or directories. -- is stdin
+	FileNames    []string
+	// Input format. Detected by file extension if empty
+	InputFormat  string
+	// Sheet name or 1-based index and cell range of xlsx input
+	Sheet        string
+	CellRange    string
+	// Nested objects of JSON input are flattened into columns joined by separator or kept as JSON
+	JsonNested    string
+	JsonSeparator string
+	// Fields of fixed width input and spaces trimmed from their values
+	Layout       []FixedWidthField
+	FixedTrim    string
 	HasHeader    bool
+	// Delimiter may be several chars or escape like \t
 	Delimiter    string
 	Encoding     string
+	// CSV dialect. Empty quote means "
+	Quote            string
+	Comment          string
+	LazyQuotes       bool
+	TrimLeadingSpace bool
+	FieldsPerRecord  int
+	LineTerminator   string
+
+	InferRows    int
+	Formats      map[string]string
+	TimeZone     string
+
+	Script       string
+	Dialect      string
+	InsertMethod string
+
+	KeyColumns    []string
+	UpdateColumns []string
+	OnConflict    string
+
+	SoftDeleteColumn string
+
+	RejectFile string
+	MaxErrors  string
+
+	// CSV column name or #index -> table column name or - to skip
+	Mapping   map[string]string
+	// Table column name -> value for every row
+	Constants map[string]string
+	// Table column name -> transform chain
+	Transforms map[string]string
+	// Table column name -> metadata kind
+	Metadata   map[string]string
+
+	// Print queries and first rows instead of changing database
+	DryRun      bool
+	PreviewRows int
+	// Load in one transaction and roll it back
+	Rollback    bool
+	// Add CSV columns missing in table
+	Evolve      bool
+	// Change types of columns which can not hold CSV values
+	Widen       bool
+
+	// Keys and indexes of created table. Every unique constraint and index is comma separated columns list.
+	PrimaryKey     []string
+	Unique         []string
+	Indexes        []string
+	Identity       string
+	IndexAfterLoad bool
 }
 
 type TableMode string
@@ -60,15 +137,139 @@ func (this TableMode) TruncatePrevious() bool {
 	return this == MODE_TRUNCATE
 }
 
+func (this TableMode) Upsert() bool {
+	return this == MODE_UPSERT
+}
+
+func (this TableMode) Sync() bool {
+	return this == MODE_SYNC
+}
+
+// Zone for date and time values without explicit zone
+func (this Config) Location() *time.Location {
+	if this.TimeZone == "" {
+		return time.Local
+	}
+	location, err := time.LoadLocation(this.TimeZone)
+	if err != nil {
+		log.Fatalf("Can not load time zone %s: %v", this.TimeZone, err)
+	}
+	return location
+}
+
+// Max count or percentage of rejected rows. Zero means any rejected row aborts loading.
+type ErrorBudget struct {
+	Count   int64
+	Percent float64
+}
+
+// Parse count (10) or percentage (0.5%)
+func ParseErrorBudget(value string) (ErrorBudget, error) {
+	if value == "" {
+		return ErrorBudget{}, nil
+	}
+	if strings.HasSuffix(value, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return ErrorBudget{}, fmt.Errorf("Percentage of errors should be between 0%% and 100%%: %s", value)
+		}
+		return ErrorBudget{Percent:percent}, nil
+	}
+	count, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || count < 0 {
+		return ErrorBudget{}, fmt.Errorf("Errors count should be non-negative integer or percentage: %s", value)
+	}
+	return ErrorBudget{Count:count}, nil
+}
+
+// Count is checked on every rejected row. Percentage is checked when all rows are read.
+func (this ErrorBudget) Exceeded(rejected, total int64) bool {
+	if this.Percent > 0 {
+		return total > 0 && float64(rejected) * 100 > this.Percent * float64(total)
+	}
+	return rejected > this.Count
+}
+
+func (this ErrorBudget) String() string {
+	if this.Percent > 0 {
+		return strconv.FormatFloat(this.Percent, 'f', -1, 64) + "%"
+	}
+	return strconv.FormatInt(this.Count, 10)
+}
+
+func (this Config) ErrorBudget() ErrorBudget {
+	budget, err := ParseErrorBudget(this.MaxErrors)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return budget
+}
+
+func (this Config) TableKeys() common.TableKeys {
+	keys := common.TableKeys{Identity:this.Identity, PrimaryKey:this.PrimaryKey}
+	for _, unique := range this.Unique {
+		keys.Unique = append(keys.Unique, splitList(unique))
+	}
+	for _, index := range this.Indexes {
+		keys.Indexes = append(keys.Indexes, splitList(index))
+	}
+	return keys
+}
+
 func (this Config) String() string {
 	return common.ObjectToJson(this, true)
 }
 
 func (this Config) Validate() {
-	if len(this.Delimiter) > 1 {
-		log.Fatalf("CSV delimiter should be a single char: %s", this.Delimiter)
-	} else if (len(this.Delimiter) == 0) {
-		log.Fatalf("Should set CSV delimiter")
+	if _, err := NewCsvDialect(this); err != nil {
+		log.Fatal(err)
+	}
+	if this.PreviewRows < 0 {
+		log.Fatalf("Rows count to preview should not be negative: %d", this.PreviewRows)
+	}
+	if this.InferRows < 0 {
+		log.Fatalf("Rows count to infer types should not be negative: %d", this.InferRows)
+	}
+	if this.TimeZone != "" {
+		if _, err := time.LoadLocation(this.TimeZone); err != nil {
+			log.Fatalf("Unknown time zone %s: %v", this.TimeZone, err)
+		}
+	}
+	if this.InputFormat != "" && !contains(inputFormats, this.InputFormat) {
+		log.Fatalf("Unsupported input format %s. Available are: %s", this.InputFormat, strings.Join(inputFormats, ", "))
+	}
+	if this.JsonNested != "" && !contains(jsonNestedModes, this.JsonNested) {
+		log.Fatalf("Unsupported nested JSON mode %s. Available are: %s", this.JsonNested, strings.Join(jsonNestedModes, ", "))
+	}
+	for i, field := range this.Layout {
+		if err := field.Validate(); err != nil {
+			log.Fatal(err)
+		}
+		for _, other := range this.Layout[:i] {
+			if other.Name == field.Name {
+				log.Fatalf("Field %s of fixed width layout is duplicated", field.Name)
+			}
+		}
+	}
+	if this.FixedTrim != "" && !contains(trimModes, this.FixedTrim) {
+		log.Fatalf("Unsupported trim mode %s. Available are: %s", this.FixedTrim, strings.Join(trimModes, ", "))
+	}
+	if _, err := ParseCellRange(this.CellRange); err != nil {
+		log.Fatal(err)
+	}
+	if this.InsertMethod != "" && !contains(insertMethods, this.InsertMethod) {
+		log.Fatalf("Unsupported insert method %s. Available are: %s", this.InsertMethod, strings.Join(insertMethods, ", "))
+	}
+	for name, kind := range this.Metadata {
+		if !contains(common.MetadataKinds, kind) {
+			log.Fatalf("Unknown metadata %s of column %s. Available are: %s", kind, name, strings.Join(common.MetadataKinds, ", "))
+		}
+	}
+	if _, err := ParseErrorBudget(this.MaxErrors); err != nil {
+		log.Fatal(err)
+	}
+	if this.OnConflict != "" && !contains(common.OnConflictPolicies, this.OnConflict) {
+		log.Fatalf("Unsupported conflict policy %s. Available are: %s", this.OnConflict, strings.Join(common.OnConflictPolicies, ", "))
 	}
 	modeOk := (string(this.TableMode) == "")
 	for _, mode := range modes {
@@ -82,17 +283,38 @@ func (this Config) Validate() {
 	}
 }
 
-func (this *Config)FillMissingFromPreset(preset Config) {
+// Empty values are taken from preset. Bool values are taken from preset if they are not set explicitly
+// because false can not be distinguished from missing flag by value.
+func (this *Config)FillMissingFromPreset(preset Config, explicit func(field string) bool) {
 	thisVal := reflect.ValueOf(this).Elem()
 	presetVal := reflect.ValueOf(preset)
 
 	for i := 0; i < thisVal.NumField(); i++ {
 		thisField := thisVal.Field(i)
 		presetField := presetVal.Field(i)
-		if thisField.Kind() == reflect.String && thisField.String() == "" && presetField.String() != "" {
-			thisField.SetString(presetField.String())
+		if explicit(thisVal.Type().Field(i).Name) {
+			continue
 		}
+		if (isEmpty(thisField) || thisField.Kind() == reflect.Bool) && !isEmpty(presetField) {
+			thisField.Set(presetField)
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmpty(value reflect.Value) bool {
+	if value.Kind() == reflect.Map || value.Kind() == reflect.Slice {
+		return value.Len() == 0
 	}
+	return value.IsZero()
 }
 
 const DEFAULT_PRESET = "default"
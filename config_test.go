@@ -1,17 +1,68 @@
 package main
 
 import (
+	"flag"
 	"testing"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/urfave/cli.v1"
 )
 
 func TestSum(t *testing.T) {
-	config := Config{Table:"table", FileName:"aaa", HasHeader:true}
-	preset := Config{Schema:"schema", FileName:"bbb", HasHeader:false}
+	config := Config{Table:"table", FileNames:[]string{"aaa"}, HasHeader:true}
+	preset := Config{Schema:"schema", FileNames:[]string{"bbb"}, HasHeader:false, DryRun:true, Evolve:true}
+	explicit := func(field string) bool {
+		return field == "HasHeader" || field == "Evolve"
+	}
 
-	config.FillMissingFromPreset(preset)
+	config.FillMissingFromPreset(preset, explicit)
 	assert.Equal(t, "schema", config.Schema)
 	assert.Equal(t, "table", config.Table)
-	assert.Equal(t, "aaa", config.FileName)
+	assert.Equal(t, []string{"aaa"}, config.FileNames)
 	assert.Equal(t, true, config.HasHeader)
+	assert.Equal(t, true, config.DryRun)
+	assert.Equal(t, false, config.Evolve)
+}
+
+func TestFillFromPresetExplicitFalse(t *testing.T) {
+	app := cli.NewApp()
+	app.Flags = []cli.Flag{
+		cli.BoolFlag{Name:HEADER_FLAG},
+		cli.BoolFlag{Name:LAZY_QUOTES_FLAG},
+		cli.BoolFlag{Name:INDEX_AFTER_LOAD_FLAG},
+	}
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range app.Flags {
+		f.Apply(set)
+	}
+	assert.Nil(t, set.Parse([]string{"--hh=false", "--lazy-quotes=false"}))
+	c := cli.NewContext(app, set, nil)
+
+	config := loadFromCliArgs(c)
+	preset := Config{HasHeader:true, LazyQuotes:true, IndexAfterLoad:true}
+	config.FillMissingFromPreset(preset, explicitFlags(c))
+	assert.Equal(t, false, config.HasHeader)
+	assert.Equal(t, false, config.LazyQuotes)
+	assert.Equal(t, true, config.IndexAfterLoad)
+}
+
+func TestErrorBudget(t *testing.T) {
+	budget, err := ParseErrorBudget("2")
+	assert.Nil(t, err)
+	assert.False(t, budget.Exceeded(2, 0))
+	assert.True(t, budget.Exceeded(3, 0))
+
+	budget, err = ParseErrorBudget("0.5%")
+	assert.Nil(t, err)
+	assert.False(t, budget.Exceeded(5, 1000))
+	assert.True(t, budget.Exceeded(6, 1000))
+	assert.Equal(t, "0.5%", budget.String())
+
+	budget, err = ParseErrorBudget("")
+	assert.Nil(t, err)
+	assert.True(t, budget.Exceeded(1, 0))
+
+	_, err = ParseErrorBudget("101%")
+	assert.Error(t, err)
+	_, err = ParseErrorBudget("many")
+	assert.Error(t, err)
 }
@@ -14,50 +14,118 @@ import (
 	"github.com/pkg/errors"
 	"github.com/and-hom/csv2db/_postgres"
 	"github.com/and-hom/csv2db/_mysql"
+	"github.com/and-hom/csv2db/_sqlite"
+	"github.com/and-hom/csv2db/_script"
 	"github.com/xo/dburl"
 	"time"
 	"github.com/machinebox/progress"
+	"strings"
+	"strconv"
+	"sort"
+	"reflect"
+	"bytes"
+	"sync/atomic"
+	"unicode/utf8"
+	"path/filepath"
 )
 
 const MIN_SIZE_BYTES_TO_SHOW_PROGRESS = 100
+const STAGING_TABLE_SUFFIX = "_csv2db_sync"
 
 type CsvToDb struct {
 	Config       Config
+	// expanded input files or -- for stdin
+	fileNames    []string
+	// fixed width files have line with column names
+	fixedHeader  bool
 	dbTool       common.DbTool
 	tableExists  bool
 	insertSchema common.InsertSchema
 	tableName    common.TableName
 	inserter     common.Inserter
+	insertMethod string
+	insertedRows int64
+	// target table of sync mode. Rows are inserted into staging table
+	syncTarget   common.TableName
+	rejects      *csv.Writer
+	rejectedRows int64
+	errorBudget  ErrorBudget
+	loadContext  common.LoadContext
+	// table is created by this run - indexes should be created
+	tableCreated bool
 }
 
 func (this *CsvToDb) Perform() error {
-	dbUrl, err := dburl.Parse(this.Config.DbUrl)
-	if err != nil {
-		log.Fatalf("Can not parse DB url: %v", err)
+	if this.Config.DryRun && this.Config.Script != "" {
+		log.Fatalf("Dry run and script can not be used together - script does not change database anyway")
 	}
-	initializeCredentialsIfMissing(dbUrl)
-
-	db, err := sql.Open(dbUrl.Driver, dbUrl.DSN)
-	if err != nil {
-		log.Fatalf("Can not connect to database: %v", err)
+	if this.Config.Rollback && (this.Config.Script != "" || this.Config.DryRun) {
+		log.Fatalf("Rollback needs database changes - it can not be used with script or dry run")
+	}
+	if err := this.checkHeaders(); err != nil {
+		log.Fatalf("Input files can not be loaded into one table: %v", err)
+	}
+	if this.Config.Script != "" {
+		script, err := _script.OpenOutput(this.Config.Script)
+		if err != nil {
+			log.Fatalf("Can not create script file %s: %v", this.Config.Script, err)
+		}
+		defer script.Close()
+		this.dbTool = this.makeScriptDbTool(script)
+	} else {
+		db, dbUrl := this.connect()
+		defer db.Close()
+		conn := common.WrapDb(db)
+		if this.Config.Rollback {
+			rollbackDb, err := common.BeginRollbackDb(db)
+			if err != nil {
+				log.Fatalf("Can not begin transaction: %v", err)
+			}
+			defer this.rollback(rollbackDb)
+			conn = rollbackDb
+		}
+		this.dbTool = this.makeDbTool(conn, dbUrl)
+		if this.Config.DryRun {
+			plan, err := _script.OpenOutput("--")
+			if err != nil {
+				return err
+			}
+			defer plan.Close()
+			this.dbTool = _script.MakeDryRunDbTool(this.dbTool, plan, this.Config.PreviewRows)
+		}
 	}
-	defer db.Close()
-	log.Debugf("Connected to %s", this.Config.DbUrl)
-
-	this.dbTool = this.makeDbTool(db, dbUrl)
 	this.tableName = this.dbTool.TableName(this.Config.Schema, this.Config.Table)
 
-	csvReader, closer, size, progressFunc, err := this.createReader()
+	csvReader, err := newInputReader(this.fileNames, this.Config.HasHeader, this.createReader)
 	if err != nil {
 		return err
 	}
-	defer closer.Close()
+	defer csvReader.Close()
+	size := this.inputSize()
+
+	this.errorBudget = this.Config.ErrorBudget()
+	this.loadContext = common.NewLoadContext()
+	log.Infof("Load id is %s", this.loadContext.LoadId)
+	if this.Config.RejectFile != "" {
+		rejectFile, err := os.Create(this.Config.RejectFile)
+		if err != nil {
+			log.Fatalf("Can not create reject file %s: %v", this.Config.RejectFile, err)
+		}
+		defer rejectFile.Close()
+		this.rejects = csv.NewWriter(rejectFile)
+		this.rejects.Comma = this.comma()
+		defer this.rejects.Flush()
+	}
 
 	this.tableExists, err = this.dbTool.Exists(this.tableName)
 	if err != nil {
 		return err
 	}
 
+	if this.Config.Rollback && !this.dbTool.TransactionalDdl() && this.changesTableDefinition() {
+		log.Fatalf("Table mode %s changes table definition which can not be rolled back by this database", this.Config.TableMode)
+	}
+
 	if this.tableExists {
 		if err = this.onTableExists(); err != nil {
 			return err
@@ -69,8 +137,8 @@ func (this *CsvToDb) Perform() error {
 		log.Warn("Can not detect column names - using col1...colN column names. Use CSV header with flag %s or create table in the database", HEADER_FLAG)
 	}
 
-	progressBar := InitProgressBar(progressFunc, size)
-	if size > MIN_SIZE_BYTES_TO_SHOW_PROGRESS {
+	progressBar := InitProgressBar(csvReader.BytesRead, size)
+	if size > MIN_SIZE_BYTES_TO_SHOW_PROGRESS && this.Config.Script != "--" && !this.Config.DryRun {
 		progressBar.Start()
 	}
 
@@ -78,16 +146,29 @@ func (this *CsvToDb) Perform() error {
 	var started time.Time
 
 	for {
-		line, err := csvReader.Read()
+		var line []string
+		var position common.RowPosition
+		if first {
+			// broken header can not be rejected
+			line, err = csvReader.Read()
+			position = common.RowPosition{FileName:csvReader.FileName(), Line:1}
+		} else {
+			line, position, err = this.readLine(csvReader)
+		}
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			log.Fatalf("Can not read CSV: %v", err)
+			return this.abort(fmt.Errorf("Can not read CSV: %v", err))
 		}
 
 		if first {
 			first = false
-			if this.initInsertSchema(line); err != nil {
+			this.writeRejectHeader(line)
+			sample, samplePositions, err := this.readSample(csvReader)
+			if err != nil {
+				return this.abort(fmt.Errorf("Can not read CSV: %v", err))
+			}
+			if err = this.initInsertSchema(line, sample, csvReader.ColumnTypes()); err != nil {
 				log.Fatalf("Can not create insert schema: %v", err)
 				return err
 			}
@@ -96,58 +177,500 @@ func (this *CsvToDb) Perform() error {
 			if err != nil {
 				return err
 			}
-			defer this.inserter.Close()
 
 			started = time.Now()
 
-			if this.Config.HasHeader {
-				continue
+			if !this.Config.HasHeader {
+				sample = append([][]string{line}, sample...)
+				samplePositions = append([]common.RowPosition{position}, samplePositions...)
 			}
+			for i, sampleLine := range sample {
+				if err = this.insert(sampleLine, samplePositions[i]); err != nil {
+					return this.abort(err)
+				}
+			}
+			continue
 		}
 
-		err = this.inserter.Add(line...)
-		if err != nil {
-			log.Errorf("Can not insert: %v", err)
-			return err
+		if err = this.insert(line, position); err != nil {
+			return this.abort(err)
 		}
 	}
 
+	if this.inserter == nil {
+		progressBar.Stop()
+		if this.Config.TableMode.Sync() {
+			this.dropStagingTable()
+		}
+		log.Warn("Input is empty - nothing to insert")
+		return nil
+	}
+	if this.errorBudget.Exceeded(this.rejectedRows, this.rejectedRows + this.insertedRows) {
+		progressBar.Stop()
+		return this.abort(fmt.Errorf("%d of %d rows rejected - more than %s",
+			this.rejectedRows, this.rejectedRows + this.insertedRows, this.errorBudget))
+	}
+	// inserters flush buffered rows and commit on close
+	if err := this.inserter.Close(); err != nil {
+		log.Errorf("Can not insert: %v", err)
+		return err
+	}
 	progressBar.Stop()
-	log.Infof("Performed in %s", time.Since(started).String())
+	this.reportThroughput(started, csvReader.BytesRead())
+	if this.tableCreated && this.Config.IndexAfterLoad {
+		if err := this.createIndexes(); err != nil {
+			return err
+		}
+	}
+	if this.rejectedRows > 0 {
+		log.Warnf("%d rows rejected", this.rejectedRows)
+	}
+
+	if this.Config.TableMode.Sync() {
+		if err := this.synchronize(); err != nil {
+			log.Errorf("Can not synchronize table %s: %v", this.syncTarget.String(), err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Discard all changes of validation run
+func (this *CsvToDb) rollback(db *common.RollbackDb) {
+	if err := db.Rollback(); err != nil {
+		log.Errorf("Can not rollback: %v", err)
+		return
+	}
+	log.Info("All changes are rolled back")
+}
+
+// Table is created, dropped or truncated or staging table is created
+func (this *CsvToDb) changesTableDefinition() bool {
+	mode := this.Config.TableMode
+	if this.tableExists {
+		return mode.DropAndCreateIfExists() || mode.TruncatePrevious() || mode.Sync()
+	}
+	return mode.CreateIfMissing() || mode.DropAndCreateIfExists()
+}
+
+func (this *CsvToDb) reportThroughput(started time.Time, bytesRead int64) {
+	elapsed := time.Since(started)
+	seconds := elapsed.Seconds()
+	if seconds == 0 {
+		seconds = time.Nanosecond.Seconds()
+	}
+	if this.Config.DryRun {
+		log.Infof("Dry run: %d rows would be inserted using %s", this.insertedRows, this.insertMethod)
+		return
+	}
+	log.Infof("Inserted %d rows using %s in %s: %.0f rows/s, %.2f MB/s", this.insertedRows, this.insertMethod,
+		elapsed.String(), float64(this.insertedRows) / seconds, float64(bytesRead) / seconds / 1024 / 1024)
+}
+
+// Dialect is taken from DB url if not set explicitly. Database is not connected.
+func (this *CsvToDb) makeScriptDbTool(out io.Writer) common.DbTool {
+	dialect := this.Config.Dialect
+	defaultSchema := ""
+	if this.Config.DbUrl != "" {
+		dbUrl := this.parseDbUrl()
+		if dialect == "" {
+			dialect = dbUrl.Driver
+		}
+		defaultSchema = strings.Trim(dbUrl.Path, "/")
+	}
+	if this.Config.InsertMethod == common.INSERT_METHOD_COPY && dialect != postgres {
+		log.Fatalf("Insert method %s is supported by %s only", this.Config.InsertMethod, postgres)
+	}
+	if this.Config.InsertMethod == common.INSERT_METHOD_LOAD_DATA {
+		log.Fatalf("Insert method %s can not be written into script", this.Config.InsertMethod)
+	}
+	if this.Config.TableMode.Sync() {
+		log.Fatalf("Table mode %s needs database connection and can not be written into script", MODE_SYNC)
+	}
+	if this.Config.InsertMethod == common.INSERT_METHOD_COPY && this.Config.TableMode.Upsert() {
+		log.Fatalf("Insert method %s does not support %s table mode", this.Config.InsertMethod, MODE_UPSERT)
+	}
+
+	this.insertMethod = this.Config.InsertMethod
+	if this.insertMethod == "" {
+		this.insertMethod = common.INSERT_METHOD_INSERT
+	}
+
+	tableExists := !this.Config.TableMode.CreateIfMissing()
+	switch dialect {
+	case postgres:
+		return _script.MakeDbTool(_postgres.MakeDialect(), out, tableExists, this.Config.InsertMethod)
+	case mysql:
+		return _script.MakeDbTool(_mysql.MakeDialect(defaultSchema), out, tableExists, this.Config.InsertMethod)
+	case sqlite:
+		return _script.MakeDbTool(_sqlite.MakeDialect(), out, tableExists, this.Config.InsertMethod)
+	default:
+		log.Fatalf("Unsupported SQL dialect %s. Set --%s or --%s", dialect, flagName(DIALECT_FLAG), flagName(DB_URL_FLAG))
+		return nil
+	}
+}
 
+func (this *CsvToDb) insert(line []string, position common.RowPosition) error {
+	values, err := common.PrepareInsertArguments(this.insertSchema, line, position)
+	if conversionErr, ok := err.(common.ConversionError); ok {
+		return this.reject(line, position, conversionErr.Column, conversionErr)
+	}
+	err = this.inserter.Add(values...)
+	if err != nil {
+		log.Errorf("Can not insert: %v", err)
+		return err
+	}
+	this.insertedRows += 1
 	return nil
 }
 
-func (this *CsvToDb) makeDbTool(db *sql.DB, dbUrl *dburl.URL) common.DbTool {
+// Next CSV row and its position. Broken rows are rejected.
+func (this *CsvToDb) readLine(csvReader *inputReader) ([]string, common.RowPosition, error) {
+	for {
+		line, err := csvReader.Read()
+		if parseErr, ok := err.(*csv.ParseError); ok {
+			position := common.RowPosition{FileName:csvReader.FileName(), Line:parseErr.StartLine}
+			if err = this.reject(line, position, "", parseErr.Err); err != nil {
+				return nil, common.RowPosition{}, err
+			}
+			continue
+		} else if err != nil {
+			return nil, common.RowPosition{}, err
+		}
+		lineNumber, _ := csvReader.FieldPos(0)
+		return line, common.RowPosition{FileName:csvReader.FileName(), Line:lineNumber}, nil
+	}
+}
+
+// Count rejected row and write it into reject file with line number, column and error.
+// Fails if error budget is exceeded.
+func (this *CsvToDb) reject(line []string, position common.RowPosition, column string, cause error) error {
+	this.rejectedRows += 1
+	if len(this.fileNames) > 1 {
+		log.Warnf("Reject row at line %d of %s: %v", position.Line, position.FileName, cause)
+	} else {
+		log.Warnf("Reject row at line %d: %v", position.Line, cause)
+	}
+	if this.rejects != nil {
+		record := append([]string{strconv.Itoa(position.Line), column, cause.Error()}, line...)
+		if err := this.rejects.Write(record); err != nil {
+			return fmt.Errorf("Can not write reject file: %v", err)
+		}
+	}
+	if this.errorBudget.Percent == 0 && this.errorBudget.Exceeded(this.rejectedRows, 0) {
+		return fmt.Errorf("%d rows rejected - more than %s", this.rejectedRows, this.errorBudget)
+	}
+	return nil
+}
+
+func (this *CsvToDb) writeRejectHeader(line []string) {
+	if this.rejects == nil || !this.Config.HasHeader {
+		return
+	}
+	if err := this.rejects.Write(append([]string{"line", "column", "error"}, line...)); err != nil {
+		log.Fatalf("Can not write reject file: %v", err)
+	}
+}
+
+// Discard inserted rows on error
+func (this *CsvToDb) abort(cause error) error {
+	log.Errorf("Load aborted: %v", cause)
+	if this.inserter != nil {
+		if err := this.inserter.Abort(); err != nil {
+			log.Errorf("Can not discard inserted rows: %v", err)
+		}
+	}
+	if this.Config.TableMode.Sync() {
+		this.dropStagingTable()
+	}
+	return cause
+}
+
+// Read first rows to infer column types if table will be created. These rows should be inserted later.
+func (this *CsvToDb) readSample(csvReader *inputReader) ([][]string, []common.RowPosition, error) {
+	sample := make([][]string, 0)
+	positions := make([]common.RowPosition, 0)
+	if !this.needsTypeInference() {
+		return sample, positions, nil
+	}
+	for len(sample) < this.Config.InferRows {
+		line, position, err := this.readLine(csvReader)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+		sample = append(sample, line)
+		positions = append(positions, position)
+	}
+	return sample, positions, nil
+}
+
+func (this *CsvToDb) needsTypeInference() bool {
+	if this.Config.InferRows == 0 {
+		return false
+	}
+	if this.tableExists {
+		// types of added columns
+		return this.Config.Evolve
+	}
+	return this.Config.TableMode.CreateIfMissing() || this.Config.TableMode.DropAndCreateIfExists()
+}
+
+func (this *CsvToDb) createIndexes() error {
+	for _, columns := range this.Config.TableKeys().Indexes {
+		log.Infof("Create index on %s (%s)", this.tableName.String(), strings.Join(columns, ", "))
+		if err := this.dbTool.CreateIndex(this.tableName, columns); err != nil {
+			return fmt.Errorf("Can not create index on %s: %v", this.tableName.String(), err)
+		}
+	}
+	return nil
+}
+
+// Target table and staging table of sync mode
+func (this *CsvToDb) alteredTables() []common.TableName {
+	tables := []common.TableName{this.schemaSource()}
+	if this.Config.TableMode.Sync() {
+		tables = append(tables, this.tableName)
+	}
+	return tables
+}
+
+// Scan whole input and change types of columns which can not hold its values
+func (this *CsvToDb) widen() error {
+	if this.fileNames[0] == STDIN {
+		return errors.New("stdin can not be read twice - use input file")
+	}
+	csvReader, err := newInputReader(this.fileNames, this.Config.HasHeader, this.createReader)
+	if err != nil {
+		return err
+	}
+	defer csvReader.Close()
+
+	widener := common.NewTypeWidener(this.insertSchema)
+	rows := 0
+	for {
+		line, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		} else if _, ok := err.(*csv.ParseError); ok {
+			// broken rows are rejected by loading
+			continue
+		} else if err != nil {
+			return err
+		}
+		rows += 1
+		if rows > 1 || !this.Config.HasHeader {
+			widener.Add(line)
+		}
+	}
+	log.Debugf("Scanned %d rows for column types", rows)
+
+	widened := widener.Widened()
+	for _, name := range widened.OrderedDbColumns {
+		colDef, _ := widened.Get(name)
+		current, _ := this.insertSchema.Get(name)
+		if this.Config.Rollback && !this.dbTool.TransactionalDdl() {
+			log.Fatalf("Column %s can not be changed - it can not be rolled back by this database", name)
+		}
+		log.Infof("Change type of column %s from %s to %s", name, current.TypeName(), colDef.TypeName())
+		for _, tableName := range this.alteredTables() {
+			if err := this.dbTool.AlterColumnType(tableName, name, colDef); err != nil {
+				return err
+			}
+		}
+		this.insertSchema.SetType(name, colDef)
+	}
+	return nil
+}
+
+// Add CSV columns missing in table as nullable columns. Staging table of sync mode gets them too.
+func (this *CsvToDb) evolve(csvSchema common.Schema, dbTableSchema *common.Schema) error {
+	if !this.Config.HasHeader && len(this.Config.Mapping) == 0 {
+		log.Warn("CSV columns are matched by index - can not add columns without CSV header")
+		return nil
+	}
+	csvSchema.ApplyTimeFormats(this.Config.Formats)
+	for _, name := range csvSchema.OrderedDbColumns {
+		if _, found := dbTableSchema.Get(name); found {
+			continue
+		}
+		if this.Config.Rollback && !this.dbTool.TransactionalDdl() {
+			log.Fatalf("Column %s can not be added - it can not be rolled back by this database", name)
+		}
+		colDef, _ := csvSchema.Get(name)
+		colDef.Nullable = true
+		log.Infof("Add column %s %s to table %s", name, colDef.TypeName(), this.schemaSource().String())
+		for _, tableName := range this.alteredTables() {
+			if err := this.dbTool.AddColumn(tableName, name, colDef); err != nil {
+				return err
+			}
+		}
+		dbTableSchema.Add(name, colDef)
+	}
+	return nil
+}
+
+func (this *CsvToDb) connect() (*sql.DB, *dburl.URL) {
+	dbUrl := this.parseDbUrl()
+	if dbUrl.Driver != sqlite {
+		initializeCredentialsIfMissing(dbUrl)
+	}
+
+	db, err := sql.Open(dbUrl.Driver, dbUrl.DSN)
+	if err != nil {
+		log.Fatalf("Can not connect to database: %v", err)
+	}
+	log.Debugf("Connected to %s", this.Config.DbUrl)
+	return db, dbUrl
+}
+
+func (this *CsvToDb) parseDbUrl() *dburl.URL {
+	dbUrl, err := dburl.Parse(this.Config.DbUrl)
+	if err != nil {
+		log.Fatalf("Can not parse DB url: %v", err)
+	}
+	return dbUrl
+}
+
+func (this *CsvToDb) makeDbTool(db common.Db, dbUrl *dburl.URL) common.DbTool {
+	this.insertMethod = this.resolveInsertMethod(db, dbUrl.Driver)
 	switch dbUrl.Driver {
 	case postgres:
-		return _postgres.MakeDbTool(db)
+		return _postgres.MakeDbTool(db, this.insertMethod)
 	case mysql:
-		return _mysql.MakeDbTool(db)
+		return _mysql.MakeDbTool(db, this.insertMethod)
+	case sqlite:
+		return _sqlite.MakeDbTool(db)
 	default:
 		log.Fatalf("Unsupported db type %s", dbUrl.Driver)
 		return nil
 	}
 }
 
-func (this *CsvToDb) initInsertSchema(line []string) error {
+// Postgres uses COPY by default. MySQL LOAD DATA skips bad rows, so it is used only if it is set explicitly.
+// Other databases support multiple rows INSERT only
+func (this *CsvToDb) resolveInsertMethod(db common.Db, driver string) string {
+	switch {
+	case this.Config.InsertMethod == common.INSERT_METHOD_COPY && driver != postgres:
+		log.Fatalf("Insert method %s is supported by %s only", this.Config.InsertMethod, postgres)
+	case this.Config.InsertMethod == common.INSERT_METHOD_LOAD_DATA && driver != mysql:
+		log.Fatalf("Insert method %s is supported by %s only", this.Config.InsertMethod, mysql)
+	case this.Config.InsertMethod != "" && this.Config.InsertMethod != common.INSERT_METHOD_INSERT && this.Config.TableMode.Upsert():
+		log.Fatalf("Insert method %s does not support %s table mode", this.Config.InsertMethod, MODE_UPSERT)
+	case this.Config.InsertMethod == common.INSERT_METHOD_LOAD_DATA && !_mysql.LocalInfileEnabled(db):
+		log.Fatalf("Insert method %s requires local_infile server variable to be ON", this.Config.InsertMethod)
+	case this.Config.InsertMethod != "":
+		return this.Config.InsertMethod
+	case this.Config.TableMode.Upsert():
+		// bulk load can not resolve conflicts
+		return common.INSERT_METHOD_INSERT
+	case driver == postgres:
+		return common.INSERT_METHOD_COPY
+	}
+	return common.INSERT_METHOD_INSERT
+}
+
+// Total size of input files or 0 for stdin
+func (this *CsvToDb) inputSize() int64 {
+	size := int64(0)
+	for _, fileName := range this.fileNames {
+		if fileName == STDIN {
+			return 0
+		}
+		info, err := os.Stat(fileName)
+		if err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// Every file should have the same header. Files without header should have the same columns count.
+func (this *CsvToDb) checkHeaders() error {
+	if len(this.fileNames) < 2 {
+		return nil
+	}
+	var first []string
+	for _, fileName := range this.fileNames {
+		csvReader, closer, _, _, err := this.createReader(fileName)
+		if err != nil {
+			return err
+		}
+		line, err := csvReader.Read()
+		closer.Close()
+		if err == io.EOF {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("Can not read first line of %s: %v", fileName, err)
+		}
+		if first == nil {
+			first = line
+		} else if !this.Config.HasHeader && len(line) != len(first) {
+			return fmt.Errorf("File %s has %d columns but %s has %d", fileName, len(line), this.fileNames[0], len(first))
+		} else if this.Config.HasHeader && !reflect.DeepEqual(line, first) {
+			return fmt.Errorf("Header of %s differs from header of %s: %s", fileName, this.fileNames[0], strings.Join(line, ", "))
+		}
+	}
+	return nil
+}
+
+// Column types known by input reader replace inferred ones
+func (this *CsvToDb) initInsertSchema(line []string, sample [][]string, columnTypes []common.ColDef) error {
 	csvSchema := this.parseCsvSchema(line)
+	csvSchema, err := csvSchema.Rename(this.Config.Mapping)
+	if err != nil {
+		return err
+	}
+	if this.needsTypeInference() {
+		if err = this.inferTypes(&csvSchema, line, sample); err != nil {
+			return err
+		}
+	}
+	applyColumnTypes(columnTypes, &csvSchema)
 	log.Debugf("CSV schema is:\n%s\n", csvSchema.ToAsciiTable())
 
+	// column types of constant and metadata columns
+	var extraSchema common.Schema
+	// column types are taken from database
+	catalogSchema := false
 	if this.tableExists {
-		dbTableSchema, err := this.dbTool.LoadSchema(this.tableName)
-		if err != nil {
+		dbTableSchema, err := this.dbTool.LoadSchema(this.schemaSource())
+		if err == common.ErrNoCatalog && (this.Config.HasHeader || len(this.Config.Mapping) > 0) {
+			log.Warnf("Can not load schema of table %s - insert CSV columns as strings", this.tableName.String())
+			this.insertSchema = csvSchema.ToInsertSchema()
+			extraSchema = this.extraSchema()
+		} else if err != nil {
 			return err
+		} else {
+			log.Debugf("DB schema is:\n%s\n", dbTableSchema.ToAsciiTable())
+			if this.Config.Evolve {
+				if err = this.evolve(csvSchema, &dbTableSchema); err != nil {
+					return fmt.Errorf("Can not add columns to table %s: %v", this.schemaSource().String(), err)
+				}
+			}
+			this.insertSchema = this.createInsertSchema(csvSchema, dbTableSchema)
+			extraSchema = dbTableSchema
+			catalogSchema = true
 		}
-		log.Debugf("DB schema is:\n%s\n", dbTableSchema.ToAsciiTable())
-		this.insertSchema = this.createInsertSchema(csvSchema, dbTableSchema)
 	} else {
 		if this.Config.TableMode.CreateIfMissing() || this.Config.TableMode.DropAndCreateIfExists() {
-			err := this.dbTool.CreateTable(this.tableName, csvSchema)
+			csvSchema.ApplyTimeFormats(this.Config.Formats)
+			extraSchema = this.extraSchema()
+			tableSchema := csvSchema.Append(extraSchema)
+			tableSchema.Keys = this.Config.TableKeys()
+			err := this.dbTool.CreateTable(this.tableName, tableSchema)
 			if err != nil {
 				log.Fatalf("Can not create table %s.%s: %v", this.tableName, err)
 				return err
 			}
+			this.tableCreated = true
+			if !this.Config.IndexAfterLoad {
+				if err := this.createIndexes(); err != nil {
+					return err
+				}
+			}
 		} else {
 			msg := fmt.Sprintf("Table %s does not exists. Please set table mode to create or create table manually",
 				this.tableName.String())
@@ -156,52 +679,382 @@ func (this *CsvToDb) initInsertSchema(line []string) error {
 		}
 		this.insertSchema = csvSchema.ToInsertSchema()
 	}
+	if err := this.addConstants(extraSchema); err != nil {
+		return err
+	}
+	if err := this.addMetadata(extraSchema); err != nil {
+		return err
+	}
+	if err := this.insertSchema.SetTimeFormats(this.Config.Formats, this.Config.Location()); err != nil {
+		return err
+	}
+	if err := this.insertSchema.SetTransforms(this.Config.Transforms); err != nil {
+		return err
+	}
+	if this.Config.Widen && catalogSchema {
+		if err := this.widen(); err != nil {
+			return fmt.Errorf("Can not widen columns of table %s: %v", this.schemaSource().String(), err)
+		}
+	}
+	if this.Config.TableMode.Upsert() {
+		upsert, err := this.upsert()
+		if err != nil {
+			return err
+		}
+		this.insertSchema.Upsert = upsert
+	}
 	log.Infof("Insert schema is:\n%s\n", this.insertSchema.ToAsciiTable())
 	return nil
 }
 
+// Key columns are taken from table primary key if not set
+func (this *CsvToDb) upsert() (common.Upsert, error) {
+	upsert := common.Upsert{
+		KeyColumns:this.Config.KeyColumns,
+		UpdateColumns:this.Config.UpdateColumns,
+		OnConflict:this.Config.OnConflict,
+	}
+	if upsert.OnConflict == "" && len(upsert.UpdateColumns) > 0 {
+		upsert.OnConflict = common.ON_CONFLICT_UPDATE_SELECTED
+	} else if upsert.OnConflict == "" {
+		upsert.OnConflict = common.ON_CONFLICT_UPDATE_ALL
+	}
+
+	keyColumns, err := this.keyColumns(this.tableName)
+	if err != nil {
+		return upsert, err
+	}
+	upsert.KeyColumns = keyColumns
+	return upsert, upsert.Validate(this.insertSchema)
+}
+
+// Staging table of sync mode may lose declared column types - so use target table schema
+func (this *CsvToDb) schemaSource() common.TableName {
+	if this.Config.TableMode.Sync() {
+		return this.syncTarget
+	}
+	return this.tableName
+}
+
+// Key columns from config or primary key of table
+func (this *CsvToDb) keyColumns(tableName common.TableName) ([]string, error) {
+	if len(this.Config.KeyColumns) > 0 {
+		return this.Config.KeyColumns, nil
+	}
+	keyColumns, err := this.dbTool.PrimaryKey(tableName)
+	if err == common.ErrNoCatalog {
+		return nil, fmt.Errorf("Can not detect primary key of %s. Set key columns with --%s",
+			tableName.String(), KEY_COLUMNS_FLAG)
+	} else if err != nil {
+		return nil, err
+	} else if len(keyColumns) == 0 {
+		return nil, fmt.Errorf("Table %s has no primary key. Set key columns with --%s",
+			tableName.String(), KEY_COLUMNS_FLAG)
+	}
+	log.Infof("Use primary key %s as key", strings.Join(keyColumns, ", "))
+	return keyColumns, nil
+}
+
+// Rows are loaded into staging table and then synchronized with target table
+func (this *CsvToDb) createStagingTable() error {
+	staging := this.dbTool.TableName(this.tableName.SchemaPlain, this.tableName.TablePlain + STAGING_TABLE_SUFFIX)
+	exists, err := this.dbTool.Exists(staging)
+	if err != nil {
+		return err
+	}
+	if exists {
+		log.Warnf("Drop staging table %s left by previous run", staging.String())
+		if err = this.dbTool.DropTable(staging); err != nil {
+			return err
+		}
+	}
+	if err = this.dbTool.CreateEmptyCopy(staging, this.tableName); err != nil {
+		return err
+	}
+	this.syncTarget = this.tableName
+	this.tableName = staging
+	return nil
+}
+
+// Insert new, update changed and delete missing rows of target table
+func (this *CsvToDb) synchronize() error {
+	defer this.dropStagingTable()
+	if this.insertedRows == 0 {
+		return fmt.Errorf("CSV has no rows - refuse to delete all rows of %s", this.syncTarget.String())
+	}
+	keyColumns, err := this.keyColumns(this.syncTarget)
+	if err != nil {
+		return err
+	}
+	sync := common.Sync{KeyColumns:keyColumns, SoftDeleteColumn:this.Config.SoftDeleteColumn}
+	for _, name := range keyColumns {
+		if _, found := this.insertSchema.Get(name); !found {
+			return fmt.Errorf("Key column %s is missing in CSV", name)
+		}
+	}
+	for _, name := range this.insertSchema.OrderedDbColumns {
+		if contains(keyColumns, name) || name == sync.SoftDeleteColumn {
+			continue
+		}
+		_, constant := this.Config.Constants[name]
+		_, metadata := this.Config.Metadata[name]
+		if constant || metadata {
+			// metadata like load time differs on every run - rows changed by it only are not updated
+			sync.SetColumns = append(sync.SetColumns, name)
+		} else {
+			sync.Columns = append(sync.Columns, name)
+		}
+	}
+
+	queries := this.dbTool.SyncQueries(this.syncTarget, this.tableName, sync)
+	counts, err := this.dbTool.ExecInTx(queries.All()...)
+	if err != nil {
+		return err
+	}
+	if this.Config.DryRun {
+		return nil
+	}
+	deleted := "deleted"
+	if sync.SoftDeleteColumn != "" {
+		deleted = "marked as deleted"
+	}
+	log.Infof("Synchronized %s: %d rows inserted, %d updated, %d %s", this.syncTarget.String(),
+		counts[1], counts[0], counts[2], deleted)
+	return nil
+}
+
+func (this *CsvToDb) dropStagingTable() {
+	if err := this.dbTool.DropTable(this.tableName); err != nil {
+		log.Warnf("Can not drop staging table %s: %v", this.tableName.String(), err)
+	}
+}
+
+// Types are inferred from transformed values
+func (this *CsvToDb) inferTypes(csvSchema *common.Schema, line []string, sample [][]string) error {
+	transforms := make(map[int]common.Transform)
+	for name, spec := range this.Config.Transforms {
+		colDef, found := csvSchema.Get(name)
+		if !found {
+			continue
+		}
+		transform, err := common.ParseTransform(spec)
+		if err != nil {
+			return fmt.Errorf("Can not parse transform of column %s: %v", name, err)
+		}
+		transforms[colDef.OrderIndex] = transform
+	}
+	transformed := func(line []string) []string {
+		result := make([]string, len(line))
+		for i, val := range line {
+			if transform, found := transforms[i]; found {
+				val = transform(val)
+			}
+			result[i] = val
+		}
+		return result
+	}
+
+	inferrer := common.NewTypeInferrer()
+	if !this.Config.HasHeader {
+		inferrer.Add(transformed(line))
+	}
+	for _, sampleLine := range sample {
+		inferrer.Add(transformed(sampleLine))
+	}
+	inferrer.Infer(csvSchema)
+	log.Debugf("Inferred CSV schema from %d rows", len(sample))
+	return nil
+}
+
+// Types of created constant and metadata columns
+func (this *CsvToDb) extraSchema() common.Schema {
+	constantsSchema := this.constantsSchema()
+	metadataSchema := common.NewSchema()
+	for _, name := range sortedKeys(this.Config.Metadata) {
+		metadataSchema.Add(name, common.MetadataColDef(this.Config.Metadata[name]))
+	}
+	return constantsSchema.Append(metadataSchema)
+}
+
+// Types of constant columns inferred from values
+func (this *CsvToDb) constantsSchema() common.Schema {
+	names := sortedKeys(this.Config.Constants)
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = this.Config.Constants[name]
+	}
+	schema := common.ParseSchema(names)
+	inferrer := common.NewTypeInferrer()
+	inferrer.Add(values)
+	inferrer.Infer(&schema)
+	return schema
+}
+
+func (this *CsvToDb) addConstants(constantsSchema common.Schema) error {
+	for _, name := range sortedKeys(this.Config.Constants) {
+		colDef, found := constantsSchema.Get(name)
+		if !found {
+			return fmt.Errorf("Constant column %s is missing in table %s", name, this.tableName.String())
+		}
+		if err := this.insertSchema.AddConstant(name, colDef, this.Config.Constants[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *CsvToDb) addMetadata(metadataSchema common.Schema) error {
+	for _, name := range sortedKeys(this.Config.Metadata) {
+		colDef, found := metadataSchema.Get(name)
+		if !found {
+			return fmt.Errorf("Metadata column %s is missing in table %s", name, this.tableName.String())
+		}
+		if err := this.insertSchema.AddMetadata(name, colDef, this.Config.Metadata[name], this.loadContext); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Mapped columns are matched by name
 func (this *CsvToDb) createInsertSchema(csvSchema, dbTableSchema common.Schema) common.InsertSchema {
-	if this.Config.HasHeader {
+	if this.Config.HasHeader || len(this.Config.Mapping) > 0 {
 		return common.CreateCsvToDbSchemaByName(csvSchema, dbTableSchema)
 	} else {
 		return common.CreateCsvToDbSchemaByIdx(csvSchema, dbTableSchema)
 	}
 }
-func (this *CsvToDb) createReader() (*csv.Reader, io.Closer, int64, func() int64, error) {
+func (this *CsvToDb) createReader(fileName string) (RowReader, io.Closer, int64, func() int64, error) {
 	var reader *os.File
 	var err error
 	size := int64(0)
-	if this.Config.FileName == "--" {
+	if fileName == STDIN {
 		reader = os.Stdin
 	} else {
-		reader, err = os.Open(this.Config.FileName)
+		reader, err = os.Open(fileName)
 		if err != nil {
-			log.Fatalf("Can not open CSV file %s: %v", this.Config.FileName, err)
+			log.Fatalf("Can not open CSV file %s: %v", fileName, err)
 			return nil, nil, 0, return0, err
 		}
 		info, err := reader.Stat()
 		if err != nil {
-			log.Warnf("Can not get file stat %s: %v", this.Config.FileName, err)
+			log.Warnf("Can not get file stat %s: %v", fileName, err)
 		} else {
 			size = info.Size()
 		}
 	}
 
+	if InputFormat(this.Config.InputFormat, fileName) == INPUT_FORMAT_PARQUET {
+		return this.createParquetReader(fileName, reader, size)
+	}
+
+	// progress is counted by compressed bytes to match file size
+	progressReader := progress.NewReader(reader)
+	decompressed, err := decompress(fileName, readCloser{Reader:progressReader, Closer:reader})
+	if err != nil {
+		reader.Close()
+		return nil, nil, 0, return0, fmt.Errorf("Can not decompress %s: %v", fileName, err)
+	}
+
+	if InputFormat(this.Config.InputFormat, fileName) == INPUT_FORMAT_XLSX {
+		cellRange, err := ParseCellRange(this.Config.CellRange)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// workbook is read completely here
+		xlsxReader, err := newXlsxReader(decompressed, this.Config.Sheet, cellRange)
+		if err != nil {
+			decompressed.Close()
+			return nil, nil, 0, return0, fmt.Errorf("Can not read workbook %s: %v", fileName, err)
+		}
+		return xlsxReader, decompressed, size, progressReader.N, nil
+	}
+
 	var encodedReader io.Reader
 	if this.Config.Encoding == "UTF-8" {
-		encodedReader = reader
+		encodedReader = decompressed
 	} else {
-		encodedReader, err = charset.NewReader(reader, this.Config.Encoding)
+		// encoding label like cp1251, cp866 or koi8-r
+		encodedReader, err = charset.NewReaderLabel(this.Config.Encoding, decompressed)
 		if err != nil {
 			log.Fatalf("Can not decode file dfrom charset %s: %v", this.Config.Encoding, err)
 			return nil, nil, 0, return0, err
 		}
 	}
-	progressReader := progress.NewReader(encodedReader)
-	fileReader := bufio.NewReader(progressReader)
-	csvReader := csv.NewReader(fileReader)
-	csvReader.Comma = ([]rune(this.Config.Delimiter))[0]
-	return csvReader, reader, size, progressReader.N, nil
+	if InputFormat(this.Config.InputFormat, fileName) == INPUT_FORMAT_NDJSON {
+		ndjsonReader, err := newNdjsonReader(encodedReader, this.Config.JsonNested, this.Config.JsonSeparator)
+		if err != nil {
+			decompressed.Close()
+			return nil, nil, 0, return0, fmt.Errorf("Can not read JSON %s: %v", fileName, err)
+		}
+		return ndjsonReader, decompressed, size, progressReader.N, nil
+	}
+	if InputFormat(this.Config.InputFormat, fileName) == INPUT_FORMAT_FIXED {
+		fixedReader, err := newFixedWidthReader(encodedReader, this.Config.Layout, this.Config.FixedTrim, this.fixedHeader)
+		if err != nil {
+			decompressed.Close()
+			return nil, nil, 0, return0, fmt.Errorf("Can not read %s: %v", fileName, err)
+		}
+		return fixedReader, decompressed, size, progressReader.N, nil
+	}
+	dialect, err := NewCsvDialect(this.Config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return dialect.NewReader(bufio.NewReader(encodedReader)), decompressed, size, progressReader.N, nil
+}
+
+// Parquet file is read by offsets, row group by row group. Stdin is read into memory.
+func (this *CsvToDb) createParquetReader(fileName string, file *os.File, size int64) (RowReader, io.Closer, int64, func() int64, error) {
+	var input io.ReaderAt = file
+	_, compressed := compressionExtensions[strings.ToLower(filepath.Ext(fileName))]
+	// Parquet is read by offsets, so stdin and compressed file are decompressed into memory
+	if fileName == STDIN || compressed {
+		decompressed, err := decompress(fileName, file)
+		if err != nil {
+			file.Close()
+			return nil, nil, 0, return0, fmt.Errorf("Can not decompress %s: %v", fileName, err)
+		}
+		content, err := io.ReadAll(decompressed)
+		if err != nil {
+			decompressed.Close()
+			return nil, nil, 0, return0, fmt.Errorf("Can not read %s: %v", fileName, err)
+		}
+		input, size = bytes.NewReader(content), int64(len(content))
+	}
+	count := int64(0)
+	parquetReader, err := newParquetReader(countingReaderAt{ReaderAt:input, count:&count}, size, file)
+	if err != nil {
+		file.Close()
+		return nil, nil, 0, return0, fmt.Errorf("Can not read Parquet file %s: %v", fileName, err)
+	}
+	progressFunc := func() int64 {
+		return min(atomic.LoadInt64(&count), size)
+	}
+	return parquetReader, parquetReader, size, progressFunc, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Delimiter of reject file. Multi-char delimiter can not be written by encoding/csv, so comma is used instead.
+func (this *CsvToDb) comma() rune {
+	delimiter, _ := Unescape(this.Config.Delimiter)
+	if utf8.RuneCountInString(delimiter) == 1 {
+		return []rune(delimiter)[0]
+	}
+	return ','
 }
 
 func return0() int64 {
@@ -236,6 +1089,12 @@ func (this *CsvToDb)onTableExists() error {
 			log.Fatalf("Can not delete all from table %s.%s: %v", this.tableName, err)
 			return err
 		}
+	} else if this.Config.TableMode.Sync() {
+		err := this.createStagingTable()
+		if err != nil {
+			log.Fatalf("Can not create staging table for %s: %v", this.tableName.String(), err)
+			return err
+		}
 	}
 	return nil
 }
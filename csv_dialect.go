@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+	"golang.org/x/text/transform"
+)
+
+// Lines end with \n or \r\n
+const LINE_TERMINATOR_AUTO = "auto"
+// Lines end with \r\n, single \n is part of value
+const LINE_TERMINATOR_CRLF = "crlf"
+// Lines end with \r
+const LINE_TERMINATOR_CR = "cr"
+
+var lineTerminators = []string{
+	LINE_TERMINATOR_AUTO,
+	LINE_TERMINATOR_CRLF,
+	LINE_TERMINATOR_CR,
+}
+
+// Unicode noncharacters should not appear in text, so they replace multi-char delimiter and \n of values in input
+const DELIMITER_SENTINEL = "\uFDD0"
+const LINE_FEED_SENTINEL = "\uFDD1"
+
+// Unescape value like \t, \x1f or \u00a6. Value without backslash is taken as is.
+func Unescape(value string) (string, error) {
+	if !strings.Contains(value, `\`) {
+		return value, nil
+	}
+	unquoted, err := strconv.Unquote(`"` + strings.Replace(value, `"`, `\"`, -1) + `"`)
+	if err != nil {
+		return "", fmt.Errorf("Bad escape sequence in %s", value)
+	}
+	return unquoted, nil
+}
+
+// Settings of encoding/csv reader. Quote char, multi-char delimiter and line terminators which it does not
+// support are replaced in input and restored in parsed values.
+type CsvDialect struct {
+	Comma            rune
+	Comment          rune
+	LazyQuotes       bool
+	TrimLeadingSpace bool
+	// 0 means count of the first row, negative one allows any count. Short rows are padded by empty values then.
+	FieldsPerRecord  int
+	replacements     []replacement
+	restorer         *strings.Replacer
+}
+
+type replacement struct {
+	from    string
+	to      string
+	// replaced back in parsed values
+	restore bool
+}
+
+func NewCsvDialect(config Config) (CsvDialect, error) {
+	dialect := CsvDialect{
+		LazyQuotes:config.LazyQuotes,
+		TrimLeadingSpace:config.TrimLeadingSpace,
+		FieldsPerRecord:config.FieldsPerRecord,
+	}
+	delimiter, err := Unescape(config.Delimiter)
+	if err != nil {
+		return CsvDialect{}, err
+	}
+	quote, err := Unescape(config.Quote)
+	if err != nil {
+		return CsvDialect{}, err
+	}
+	comment, err := Unescape(config.Comment)
+	if err != nil {
+		return CsvDialect{}, err
+	}
+	if delimiter == "" {
+		return CsvDialect{}, errors.New("Should set CSV delimiter")
+	}
+	if strings.ContainsAny(delimiter + quote + comment, "\r\n") {
+		return CsvDialect{}, errors.New("CSV delimiter, quote and comment can not contain line terminators")
+	}
+	if quote != "" && utf8.RuneCountInString(quote) != 1 || utf8.RuneCountInString(comment) > 1 {
+		return CsvDialect{}, errors.New("CSV quote and comment should be single chars")
+	}
+	if quote == "" {
+		quote = `"`
+	}
+	if strings.Contains(delimiter, quote) || comment != "" && (comment == quote || strings.Contains(delimiter, comment)) {
+		return CsvDialect{}, errors.New("CSV delimiter, quote and comment should differ")
+	}
+
+	if utf8.RuneCountInString(delimiter) > 1 {
+		dialect.replacements = append(dialect.replacements, replacement{from:delimiter, to:DELIMITER_SENTINEL, restore:true})
+		delimiter = DELIMITER_SENTINEL
+	}
+	switch config.LineTerminator {
+	case LINE_TERMINATOR_CRLF:
+		dialect.replacements = append(dialect.replacements,
+			replacement{from:"\r\n", to:"\n"},
+			replacement{from:"\n", to:LINE_FEED_SENTINEL, restore:true})
+	case LINE_TERMINATOR_CR:
+		dialect.replacements = append(dialect.replacements,
+			replacement{from:"\r", to:"\n", restore:true},
+			replacement{from:"\n", to:"\r", restore:true})
+	case "", LINE_TERMINATOR_AUTO:
+	default:
+		return CsvDialect{}, fmt.Errorf("Unsupported line terminator %s. Available are: %s", config.LineTerminator, strings.Join(lineTerminators, ", "))
+	}
+	if quote != `"` {
+		dialect.replacements = append(dialect.replacements,
+			replacement{from:quote, to:`"`, restore:true},
+			replacement{from:`"`, to:quote, restore:true})
+	}
+
+	dialect.Comma, _ = utf8.DecodeRuneInString(dialect.replaced(delimiter))
+	if comment != "" {
+		dialect.Comment, _ = utf8.DecodeRuneInString(dialect.replaced(comment))
+	}
+	restored := make([]string, 0)
+	for _, r := range dialect.replacements {
+		if r.restore {
+			restored = append(restored, r.to, r.from)
+		}
+	}
+	if len(restored) > 0 {
+		dialect.restorer = strings.NewReplacer(restored...)
+	}
+	return dialect, nil
+}
+
+// Char as it is seen by csv.Reader after replacements
+func (this CsvDialect) replaced(char string) string {
+	for _, r := range this.replacements {
+		if r.from == char {
+			return r.to
+		}
+	}
+	return char
+}
+
+func (this CsvDialect) NewReader(input io.Reader) RowReader {
+	if len(this.replacements) > 0 {
+		input = transform.NewReader(input, replacer{replacements:this.replacements})
+	}
+	csvReader := csv.NewReader(input)
+	csvReader.Comma = this.Comma
+	csvReader.Comment = this.Comment
+	csvReader.LazyQuotes = this.LazyQuotes
+	csvReader.TrimLeadingSpace = this.TrimLeadingSpace
+	csvReader.FieldsPerRecord = this.FieldsPerRecord
+	if this.restorer == nil && this.FieldsPerRecord >= 0 {
+		return csvReader
+	}
+	return &dialectReader{Reader:csvReader, restorer:this.restorer, pad:this.FieldsPerRecord < 0}
+}
+
+// Restores replaced chars in values and pads short rows
+type dialectReader struct {
+	*csv.Reader
+	restorer *strings.Replacer
+	pad      bool
+	width    int
+}
+
+func (this *dialectReader) Read() ([]string, error) {
+	// row with wrong fields count is returned with error
+	line, err := this.Reader.Read()
+	if this.restorer != nil {
+		for i, val := range line {
+			line[i] = this.restorer.Replace(val)
+		}
+	}
+	if err != nil {
+		return line, err
+	}
+	if this.pad {
+		if this.width == 0 {
+			this.width = len(line)
+		}
+		for len(line) < this.width {
+			line = append(line, "")
+		}
+	}
+	return line, nil
+}
+
+// Transformer replacing byte sequences. Earlier replacement wins if several ones match.
+type replacer struct {
+	replacements []replacement
+}
+
+func (this replacer) Reset() {
+}
+
+func (this replacer) Transform(dst, src []byte, atEOF bool) (int, int, error) {
+	nDst, nSrc := 0, 0
+	for nSrc < len(src) {
+		from, to := src[nSrc:nSrc + 1], src[nSrc:nSrc + 1]
+		for _, r := range this.replacements {
+			if bytes.HasPrefix(src[nSrc:], []byte(r.from)) {
+				from, to = []byte(r.from), []byte(r.to)
+				break
+			}
+			if !atEOF && bytes.HasPrefix([]byte(r.from), src[nSrc:]) {
+				// the rest of input may match
+				return nDst, nSrc, transform.ErrShortSrc
+			}
+		}
+		if nDst + len(to) > len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		nDst += copy(dst[nDst:], to)
+		nSrc += len(from)
+	}
+	return nDst, nSrc, nil
+}
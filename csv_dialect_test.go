@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"strings"
+	"encoding/csv"
+	"io"
+	"github.com/stretchr/testify/assert"
+)
+
+func readAll(t *testing.T, config Config, input string) [][]string {
+	dialect, err := NewCsvDialect(config)
+	assert.Nil(t, err)
+	reader := dialect.NewReader(strings.NewReader(input))
+	lines := make([][]string, 0)
+	for {
+		line, err := reader.Read()
+		if err == io.EOF {
+			return lines
+		}
+		assert.Nil(t, err)
+		lines = append(lines, line)
+	}
+}
+
+func TestCsvDialect(t *testing.T) {
+	assert.Equal(t, [][]string{{"a", "b||c", `x"y`}, {"1", "'2'", ""}},
+		readAll(t, Config{Delimiter:"||", Quote:"'"}, "a||'b||c'||x\"y\n1||'''2'''||\n"))
+	assert.Equal(t, [][]string{{"a", "b"}, {"1", "2"}},
+		readAll(t, Config{Delimiter:`\t`, Comment:"#", TrimLeadingSpace:true}, "a\t b\n# note\n1\t2\n"))
+	assert.Equal(t, [][]string{{"a", "b\nc"}, {"1", "2"}},
+		readAll(t, Config{Delimiter:";", LineTerminator:LINE_TERMINATOR_CRLF}, "a;b\nc\r\n1;2\r\n"))
+	assert.Equal(t, [][]string{{"a", "b"}, {"1", "x\ny"}},
+		readAll(t, Config{Delimiter:",", LineTerminator:LINE_TERMINATOR_CR}, "a,b\r1,x\ny\r"))
+	assert.Equal(t, [][]string{{"a", "b", "c"}, {"1", "", ""}, {"1", "2", "3", "4"}},
+		readAll(t, Config{Delimiter:",", FieldsPerRecord:-1}, "a,b,c\n1\n1,2,3,4\n"))
+	assert.Equal(t, [][]string{{"a", `say "hi"`}},
+		readAll(t, Config{Delimiter:";", LazyQuotes:true}, "a;say \"hi\"\n"))
+}
+
+func TestCsvDialectErrors(t *testing.T) {
+	dialect, _ := NewCsvDialect(Config{Delimiter:";"})
+	_, err := dialect.NewReader(strings.NewReader("a;say \"hi\"\n")).Read()
+	_, isParseError := err.(*csv.ParseError)
+	assert.True(t, isParseError)
+	_, err = NewCsvDialect(Config{Delimiter:""})
+	assert.NotNil(t, err)
+	_, err = NewCsvDialect(Config{Delimiter:",", Quote:"''"})
+	assert.NotNil(t, err)
+	_, err = NewCsvDialect(Config{Delimiter:",", Quote:","})
+	assert.NotNil(t, err)
+	_, err = NewCsvDialect(Config{Delimiter:",", LineTerminator:"lf"})
+	assert.NotNil(t, err)
+}
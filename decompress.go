@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"strings"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const COMPRESSION_GZIP = "gzip"
+const COMPRESSION_BZIP2 = "bzip2"
+const COMPRESSION_XZ = "xz"
+const COMPRESSION_ZSTD = "zstd"
+
+var compressionExtensions = map[string]string{
+	".gz":COMPRESSION_GZIP,
+	".gzip":COMPRESSION_GZIP,
+	".bz2":COMPRESSION_BZIP2,
+	".xz":COMPRESSION_XZ,
+	".zst":COMPRESSION_ZSTD,
+	".zstd":COMPRESSION_ZSTD,
+}
+
+// Magic bytes at the beginning of compressed input. Dots match any byte.
+var compressionMagic = []struct {
+	compression string
+	magic       []byte
+}{
+	{COMPRESSION_GZIP, []byte{0x1f, 0x8b}},
+	// block size digit follows BZh
+	{COMPRESSION_BZIP2, []byte("BZh.1AY&SY")},
+	{COMPRESSION_XZ, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{COMPRESSION_ZSTD, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+}
+
+// Compression detected by file extension or by magic bytes of input. Empty string means plain input.
+func detectCompression(fileName string, reader *bufio.Reader) string {
+	if compression, found := compressionExtensions[strings.ToLower(filepath.Ext(fileName))]; found {
+		return compression
+	}
+	for _, format := range compressionMagic {
+		head, _ := reader.Peek(len(format.magic))
+		if matchMagic(head, format.magic) {
+			return format.compression
+		}
+	}
+	return ""
+}
+
+func matchMagic(head, magic []byte) bool {
+	if len(head) < len(magic) {
+		return false
+	}
+	for i, b := range magic {
+		if b != '.' && head[i] != b {
+			return false
+		}
+	}
+	return true
+}
+
+// Decompress input on the fly. Plain input is returned as is. Closing result closes input too.
+func decompress(fileName string, input io.ReadCloser) (io.ReadCloser, error) {
+	bufReader := bufio.NewReader(input)
+	switch compression := detectCompression(fileName, bufReader); compression {
+	case COMPRESSION_GZIP:
+		gzipReader, err := gzip.NewReader(bufReader)
+		if err != nil {
+			return nil, fmt.Errorf("Can not read %s input: %v", compression, err)
+		}
+		return decompressedReader{Reader:gzipReader, closers:[]io.Closer{gzipReader, input}}, nil
+	case COMPRESSION_BZIP2:
+		return decompressedReader{Reader:bzip2.NewReader(bufReader), closers:[]io.Closer{input}}, nil
+	case COMPRESSION_XZ:
+		xzReader, err := xz.NewReader(bufReader)
+		if err != nil {
+			return nil, fmt.Errorf("Can not read %s input: %v", compression, err)
+		}
+		return decompressedReader{Reader:xzReader, closers:[]io.Closer{input}}, nil
+	case COMPRESSION_ZSTD:
+		zstdReader, err := zstd.NewReader(bufReader, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("Can not read %s input: %v", compression, err)
+		}
+		return decompressedReader{Reader:zstdReader, closers:[]io.Closer{zstdReader.IOReadCloser(), input}}, nil
+	default:
+		return decompressedReader{Reader:bufReader, closers:[]io.Closer{input}}, nil
+	}
+}
+
+type decompressedReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (this decompressedReader) Close() error {
+	var result error
+	for _, closer := range this.closers {
+		if err := closer.Close(); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecompressByMagic(t *testing.T) {
+	compressed := bytes.Buffer{}
+	writer := gzip.NewWriter(&compressed)
+	writer.Write([]byte("id,name\n1,a\n"))
+	writer.Close()
+
+	reader, err := decompress("stdin", io.NopCloser(&compressed))
+	assert.Nil(t, err)
+	content, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, "id,name\n1,a\n", string(content))
+
+	reader, err = decompress("plain.csv", io.NopCloser(bytes.NewBufferString("BZh,x\n")))
+	assert.Nil(t, err)
+	content, _ = io.ReadAll(reader)
+	assert.Equal(t, "BZh,x\n", string(content))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"github.com/and-hom/csv2db/common"
+)
+
+// Spaces trimmed from fixed width fields
+const TRIM_RIGHT = "right"
+const TRIM_BOTH = "both"
+const TRIM_NONE = "none"
+
+var trimModes = []string{
+	TRIM_RIGHT,
+	TRIM_BOTH,
+	TRIM_NONE,
+}
+
+// Field of fixed width line. Start is 1-based position in chars. Type is optional type of created column.
+type FixedWidthField struct {
+	Name   string
+	Start  int
+	Length int
+	Type   string
+}
+
+// Parse field like name:start:length[:type]
+func ParseFixedWidthField(value string) (FixedWidthField, error) {
+	parts := strings.SplitN(value, ":", 4)
+	if len(parts) < 3 {
+		return FixedWidthField{}, fmt.Errorf("Field should look like name:start:length[:type]: %s", value)
+	}
+	start, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return FixedWidthField{}, fmt.Errorf("Bad start of field %s: %v", value, err)
+	}
+	length, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return FixedWidthField{}, fmt.Errorf("Bad length of field %s: %v", value, err)
+	}
+	field := FixedWidthField{Name:parts[0], Start:start, Length:length}
+	if len(parts) == 4 {
+		field.Type = parts[3]
+	}
+	return field, field.Validate()
+}
+
+func (this FixedWidthField) Validate() error {
+	if this.Name == "" || this.Start < 1 || this.Length < 1 {
+		return fmt.Errorf("Field should have name, start from 1 and positive length: %+v", this)
+	}
+	if this.Type != "" {
+		if _, err := common.ParseColDef(this.Type); err != nil {
+			return fmt.Errorf("Bad type of field %s: %v", this.Name, err)
+		}
+	}
+	return nil
+}
+
+// Rows of fixed width text. The first row is made of field names like CSV header.
+// Line shorter than layout has empty trailing fields. Empty lines are skipped.
+type fixedWidthReader struct {
+	input      *bufio.Reader
+	layout     []FixedWidthField
+	trim       string
+	line       int
+	headerRead bool
+}
+
+// First line is skipped if file has own header
+func newFixedWidthReader(input io.Reader, layout []FixedWidthField, trim string, skipHeader bool) (*fixedWidthReader, error) {
+	reader := &fixedWidthReader{input:bufio.NewReader(input), layout:layout, trim:trim}
+	if skipHeader {
+		if _, err := reader.readLine(); err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+	return reader, nil
+}
+
+func (this *fixedWidthReader) readLine() (string, error) {
+	content, err := this.input.ReadString('\n')
+	if err != nil && (err != io.EOF || content == "") {
+		return "", err
+	}
+	this.line += 1
+	return strings.TrimRight(content, "\r\n"), nil
+}
+
+func (this *fixedWidthReader) Read() ([]string, error) {
+	if !this.headerRead {
+		this.headerRead = true
+		header := make([]string, len(this.layout))
+		for i, field := range this.layout {
+			header[i] = field.Name
+		}
+		return header, nil
+	}
+	for {
+		content, err := this.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if content == "" {
+			continue
+		}
+		chars := []rune(content)
+		line := make([]string, len(this.layout))
+		for i, field := range this.layout {
+			start := min(field.Start - 1, len(chars))
+			end := min(start + field.Length, len(chars))
+			line[i] = this.trimmed(string(chars[start:end]))
+		}
+		return line, nil
+	}
+}
+
+func (this *fixedWidthReader) trimmed(value string) string {
+	switch this.trim {
+	case TRIM_NONE:
+		return value
+	case TRIM_BOTH:
+		return strings.TrimSpace(value)
+	default:
+		return strings.TrimRight(value, " \t")
+	}
+}
+
+func (this *fixedWidthReader) FieldPos(field int) (int, int) {
+	if field < len(this.layout) {
+		return this.line, this.layout[field].Start
+	}
+	return this.line, 1
+}
+
+// Types declared by layout. Type of field without declared type is not known.
+// Declared columns are nullable because blank field is usual for missing value.
+func (this *fixedWidthReader) ColumnTypes() []common.ColDef {
+	colDefs := make([]common.ColDef, len(this.layout))
+	for i, field := range this.layout {
+		if field.Type != "" {
+			colDefs[i], _ = common.ParseColDef(field.Type)
+			colDefs[i].Nullable = true
+		}
+	}
+	return colDefs
+}
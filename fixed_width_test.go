@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"strings"
+	"io"
+	"reflect"
+	"github.com/stretchr/testify/assert"
+	"github.com/and-hom/csv2db/common"
+)
+
+func TestParseFixedWidthField(t *testing.T) {
+	field, err := ParseFixedWidthField("amount:11:12:decimal(12,2)")
+	assert.Nil(t, err)
+	assert.Equal(t, FixedWidthField{Name:"amount", Start:11, Length:12, Type:"decimal(12,2)"}, field)
+	_, err = ParseFixedWidthField("amount:0:12")
+	assert.NotNil(t, err)
+	_, err = ParseFixedWidthField("amount:1:12:money")
+	assert.NotNil(t, err)
+	_, err = ParseFixedWidthField("amount:1")
+	assert.NotNil(t, err)
+}
+
+func TestFixedWidthReader(t *testing.T) {
+	layout := []FixedWidthField{{Name:"id", Start:1, Length:3}, {Name:"name", Start:4, Length:6}, {Name:"sum", Start:10, Length:5}}
+	input := "ID NAME  SUM\r\n001Иван   12.5\r\n\r\n002 Ann\n"
+	reader, err := newFixedWidthReader(strings.NewReader(input), layout, TRIM_RIGHT, true)
+	assert.Nil(t, err)
+	line, _ := reader.Read()
+	assert.Equal(t, []string{"id", "name", "sum"}, line)
+	line, _ = reader.Read()
+	assert.Equal(t, []string{"001", "Иван", " 12.5"}, line)
+	line, _ = reader.Read()
+	assert.Equal(t, []string{"002", " Ann", ""}, line)
+	lineNumber, column := reader.FieldPos(1)
+	assert.Equal(t, 4, lineNumber)
+	assert.Equal(t, 4, column)
+	_, err = reader.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestFixedWidthColumnTypes(t *testing.T) {
+	layout := []FixedWidthField{{Name:"id", Start:1, Length:3, Type:"int"}, {Name:"sum", Start:4, Length:5}}
+	reader, _ := newFixedWidthReader(strings.NewReader("   12.50\n"), layout, TRIM_BOTH, false)
+	schema := common.NColsSchema(2)
+	applyColumnTypes(reader.ColumnTypes(), &schema)
+	id, _ := schema.Get(schema.OrderedDbColumns[0])
+	assert.Equal(t, common.ColDef{GoType:reflect.Int32, OrderIndex:0, Nullable:true}, id)
+
+	// blank numeric field is null
+	reader.Read()
+	line, _ := reader.Read()
+	assert.Equal(t, "", line[0])
+	val, err := common.NullableMapper{Source:common.Int32ValMapper}.Apply(line[0])
+	assert.Nil(t, err)
+	assert.Nil(t, val)
+	sum, _ := schema.Get(schema.OrderedDbColumns[1])
+	assert.Equal(t, reflect.String, sum.GoType)
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"fmt"
+	"errors"
+	"regexp"
+	"reflect"
+	"github.com/and-hom/csv2db/common"
+	log "github.com/sirupsen/logrus"
+)
+
+const STDIN = "--"
+// Part of table name, reject file or script file replaced by input file name without extension
+const BASENAME_TEMPLATE = "{basename}"
+
+var notIdentifierChars = regexp.MustCompile("[^A-Za-z0-9_]+")
+
+const INPUT_FORMAT_CSV = "csv"
+const INPUT_FORMAT_XLSX = "xlsx"
+const INPUT_FORMAT_NDJSON = "ndjson"
+const INPUT_FORMAT_FIXED = "fixed"
+const INPUT_FORMAT_PARQUET = "parquet"
+
+var inputFormats = []string{
+	INPUT_FORMAT_CSV,
+	INPUT_FORMAT_XLSX,
+	INPUT_FORMAT_NDJSON,
+	INPUT_FORMAT_FIXED,
+	INPUT_FORMAT_PARQUET,
+}
+
+var inputFormatExtensions = map[string]string{
+	".xlsx":INPUT_FORMAT_XLSX,
+	".xlsm":INPUT_FORMAT_XLSX,
+	".ndjson":INPUT_FORMAT_NDJSON,
+	".jsonl":INPUT_FORMAT_NDJSON,
+	".parquet":INPUT_FORMAT_PARQUET,
+}
+
+// Source of rows of one input file. *csv.Reader is the simplest one.
+type RowReader interface {
+	// Broken row is returned with *csv.ParseError and reading may be continued
+	Read() ([]string, error)
+	// Line and column of field of the last row
+	FieldPos(field int) (int, int)
+}
+
+// Reader of input which knows column types like Parquet file or fixed width layout
+type TypedRowReader interface {
+	RowReader
+	// Column types by order index. Invalid go type means type is not known, but nullable flag is still used.
+	ColumnTypes() []common.ColDef
+}
+
+// Replace column types by types known by reader. Columns are matched by order index, so mapping may rename them.
+func applyColumnTypes(colDefs []common.ColDef, schema *common.Schema) {
+	for _, name := range schema.OrderedDbColumns {
+		colDef, _ := schema.Get(name)
+		if colDef.OrderIndex < 0 || colDef.OrderIndex >= len(colDefs) {
+			continue
+		}
+		known := colDefs[colDef.OrderIndex]
+		if known.GoType == reflect.Invalid {
+			// type is inferred, but reader may know that column has nulls
+			colDef.Nullable = colDef.Nullable || known.Nullable
+			schema.Set(name, colDef)
+			continue
+		}
+		known.OrderIndex = colDef.OrderIndex
+		known.Nullable = known.Nullable || colDef.Nullable
+		schema.Set(name, known)
+	}
+}
+
+// Format set explicitly or detected by file extension. Compression extension is skipped. Stdin is CSV by default.
+func InputFormat(format, fileName string) string {
+	if format != "" {
+		return format
+	}
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if _, compressed := compressionExtensions[ext]; compressed {
+		ext = strings.ToLower(filepath.Ext(strings.TrimSuffix(fileName, filepath.Ext(fileName))))
+	}
+	if detected, found := inputFormatExtensions[ext]; found {
+		return detected
+	}
+	return INPUT_FORMAT_CSV
+}
+
+// Expand glob patterns and directories into sorted file lists. Stdin can not be combined with files.
+func ExpandInputs(inputs []string) ([]string, error) {
+	if len(inputs) == 0 {
+		return nil, errors.New("No input file is set")
+	}
+	fileNames := make([]string, 0)
+	for _, input := range inputs {
+		if input == STDIN {
+			if len(inputs) > 1 {
+				return nil, errors.New("Stdin can not be read together with files")
+			}
+			return []string{STDIN}, nil
+		}
+		matches, err := filepath.Glob(input)
+		if err != nil {
+			return nil, fmt.Errorf("Bad glob pattern %s: %v", input, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("No files found by %s", input)
+		}
+		for _, match := range matches {
+			files, err := filesOf(match)
+			if err != nil {
+				return nil, err
+			}
+			fileNames = append(fileNames, files...)
+		}
+	}
+	return fileNames, nil
+}
+
+// Path itself or regular files of directory except hidden ones
+func filesOf(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("Directory %s has no files", path)
+	}
+	return files, nil
+}
+
+// Replace {basename} by file name without directory and extension. Chars not allowed in identifiers become _
+func ApplyBasename(template, fileName string) string {
+	base := filepath.Base(fileName)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	return strings.Replace(template, BASENAME_TEMPLATE, notIdentifierChars.ReplaceAllString(base, "_"), -1)
+}
+
+// Load every input file. Files are loaded into one table by one run or into own tables if table name has {basename}
+func Load(config Config) error {
+	fileNames, err := ExpandInputs(config.FileNames)
+	if err != nil {
+		log.Fatalf("Can not find input files: %v", err)
+	}
+	if config.InputFormat == "" && len(config.Layout) > 0 {
+		config.InputFormat = INPUT_FORMAT_FIXED
+	}
+	// line of fixed width file with column names is skipped
+	fixedHeader := false
+	switch InputFormat(config.InputFormat, fileNames[0]) {
+	case INPUT_FORMAT_NDJSON, INPUT_FORMAT_PARQUET:
+		// keys of JSON objects and Parquet fields are column names
+		config.HasHeader = true
+	case INPUT_FORMAT_FIXED:
+		if len(config.Layout) == 0 {
+			log.Fatalf("Fixed width input needs fields layout")
+		}
+		// names of layout fields are column names
+		fixedHeader, config.HasHeader = config.HasHeader, true
+	}
+	if !strings.Contains(config.Table, BASENAME_TEMPLATE) {
+		return (&CsvToDb{Config:config, fileNames:fileNames, fixedHeader:fixedHeader}).Perform()
+	}
+	if fileNames[0] == STDIN {
+		log.Fatalf("Table name %s needs input file name", config.Table)
+	}
+	for _, output := range []string{config.RejectFile, config.Script} {
+		if len(fileNames) > 1 && output != "" && output != STDIN && !strings.Contains(output, BASENAME_TEMPLATE) {
+			log.Fatalf("File %s would be overwritten by every input - add %s into its name", output, BASENAME_TEMPLATE)
+		}
+	}
+	for _, fileName := range fileNames {
+		fileConfig := config
+		fileConfig.Table = ApplyBasename(config.Table, fileName)
+		fileConfig.RejectFile = ApplyBasename(config.RejectFile, fileName)
+		fileConfig.Script = ApplyBasename(config.Script, fileName)
+		log.Infof("Load %s into table %s", fileName, fileConfig.Table)
+		if err := (&CsvToDb{Config:fileConfig, fileNames:[]string{fileName}, fixedHeader:fixedHeader}).Perform(); err != nil {
+			return fmt.Errorf("Can not load %s: %v", fileName, err)
+		}
+	}
+	return nil
+}
+
+// Rows of input files read one after another. Header of every file but the first one is skipped.
+type inputReader struct {
+	fileNames    []string
+	skipHeader   bool
+	open         func(fileName string) (RowReader, io.Closer, int64, func() int64, error)
+	index        int
+	current      RowReader
+	closer       io.Closer
+	progressFunc func() int64
+	// bytes of files read completely
+	bytesDone    int64
+	columnTypes  []common.ColDef
+}
+
+func newInputReader(fileNames []string, skipHeader bool,
+open func(fileName string) (RowReader, io.Closer, int64, func() int64, error)) (*inputReader, error) {
+	reader := &inputReader{fileNames:fileNames, skipHeader:skipHeader, open:open}
+	return reader, reader.openCurrent()
+}
+
+func (this *inputReader) openCurrent() error {
+	// reader of previous file is kept if the next one can not be opened
+	current, closer, _, progressFunc, err := this.open(this.fileNames[this.index])
+	if err != nil {
+		return err
+	}
+	this.current, this.closer, this.progressFunc = current, closer, progressFunc
+	if typed, ok := current.(TypedRowReader); ok && this.index == 0 {
+		this.columnTypes = typed.ColumnTypes()
+	}
+	if this.index == 0 || !this.skipHeader {
+		return nil
+	}
+	if _, err = this.current.Read(); err != nil && err != io.EOF {
+		return fmt.Errorf("Can not read header of %s: %v", this.FileName(), err)
+	}
+	return nil
+}
+
+func (this *inputReader) Read() ([]string, error) {
+	for {
+		line, err := this.current.Read()
+		if err != io.EOF || this.index == len(this.fileNames) - 1 {
+			return line, err
+		}
+		this.bytesDone += this.progressFunc()
+		this.closer.Close()
+		this.closer = nil
+		this.index += 1
+		if err = this.openCurrent(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (this *inputReader) FieldPos(field int) (int, int) {
+	return this.current.FieldPos(field)
+}
+
+// File of the last row
+func (this *inputReader) FileName() string {
+	if this.fileNames[this.index] == STDIN {
+		return "stdin"
+	}
+	return this.fileNames[this.index]
+}
+
+// Column types known by reader of the first file or nil
+func (this *inputReader) ColumnTypes() []common.ColDef {
+	return this.columnTypes
+}
+
+func (this *inputReader) BytesRead() int64 {
+	return this.bytesDone + this.progressFunc()
+}
+
+func (this *inputReader) Close() error {
+	if this.closer == nil {
+		return nil
+	}
+	return this.closer.Close()
+}
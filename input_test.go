@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"path/filepath"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandInputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.csv", "a.csv", "sub/c.csv", "sub/.hidden"} {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		os.WriteFile(filepath.Join(dir, name), []byte("x\n"), 0644)
+	}
+
+	fileNames, err := ExpandInputs([]string{filepath.Join(dir, "*.csv"), filepath.Join(dir, "sub")})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a.csv"), filepath.Join(dir, "b.csv"), filepath.Join(dir, "sub", "c.csv")}, fileNames)
+
+	_, err = ExpandInputs([]string{filepath.Join(dir, "*.json")})
+	assert.Error(t, err)
+	_, err = ExpandInputs([]string{STDIN, filepath.Join(dir, "a.csv")})
+	assert.Error(t, err)
+}
+
+func TestApplyBasename(t *testing.T) {
+	assert.Equal(t, "items_2024_01", ApplyBasename("items_{basename}", "/data/2024-01.csv"))
+	assert.Equal(t, "items", ApplyBasename("items", "/data/2024-01.csv"))
+}
+
+func TestInputReaderOpenFailure(t *testing.T) {
+	closed := 0
+	open := func(fileName string) (RowReader, io.Closer, int64, func() int64, error) {
+		if fileName == "bad.csv.gz" {
+			return nil, nil, 0, nil, errors.New("corrupt")
+		}
+		return csv.NewReader(strings.NewReader("a\n1\n")), closerFunc(func() error {
+			closed += 1
+			return nil
+		}), 4, func() int64 { return 4 }, nil
+	}
+	reader, err := newInputReader([]string{"a.csv", "bad.csv.gz"}, true, open)
+	assert.Nil(t, err)
+	reader.Read()
+	reader.Read()
+	_, err = reader.Read()
+	assert.EqualError(t, err, "corrupt")
+	assert.Nil(t, reader.Close())
+	assert.Equal(t, 1, closed)
+}
+
+type closerFunc func() error
+
+func (this closerFunc) Close() error {
+	return this()
+}
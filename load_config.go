@@ -11,13 +11,43 @@ func LoadConfig(c *cli.Context) Config {
 	configStorage := LoadConfigStorage()
 	preset := getPreset(c, configStorage)
 
-	loadedConfig.FillMissingFromPreset(preset)
+	loadedConfig.FillMissingFromPreset(preset, explicitFlags(c))
+	// layout of fixed width input is usually taken from preset
+	loadedConfig.Validate()
 
 	setPreset(c, configStorage, loadedConfig)
 
 	return loadedConfig
 }
 
+// Bool fields set in command line by any name of their flag like --has-header=false or --hh.
+// Config fields of bool flags are named after flag long names: has-header -> HasHeader
+func explicitFlags(c *cli.Context) func(field string) bool {
+	explicit := make(map[string]bool)
+	for _, f := range c.App.Flags {
+		boolFlag, ok := f.(cli.BoolFlag)
+		if !ok {
+			continue
+		}
+		for _, name := range strings.Split(boolFlag.Name, ",") {
+			if c.IsSet(strings.TrimSpace(name)) {
+				explicit[fieldName(boolFlag.Name)] = true
+			}
+		}
+	}
+	return func(field string) bool {
+		return explicit[field]
+	}
+}
+
+func fieldName(flag string) string {
+	parts := strings.Split(flagName(flag), "-")
+	for i, part := range parts {
+		parts[i] = strings.ToUpper(part[:1]) + part[1:]
+	}
+	return strings.Join(parts, "")
+}
+
 func loadFromCliArgs(c *cli.Context) Config {
 	tableParts := strings.Split(c.String(flagName(TABLE_FLAG)), ".")
 	schemaName := ""
@@ -31,15 +61,99 @@ func loadFromCliArgs(c *cli.Context) Config {
 		Table:tableParts[len(tableParts) - 1],
 		TableMode:TableMode(c.String(flagName(TABLE_MODE_FLAG))),
 
-		FileName : c.String(flagName(INPUT_FILE_FLAG)),
+		FileNames : c.StringSlice(flagName(INPUT_FILE_FLAG)),
+		InputFormat : c.String(flagName(INPUT_FORMAT_FLAG)),
+		Sheet : c.String(flagName(SHEET_FLAG)),
+		CellRange : c.String(flagName(RANGE_FLAG)),
+		JsonNested : c.String(flagName(JSON_NESTED_FLAG)),
+		JsonSeparator : c.String(flagName(JSON_SEPARATOR_FLAG)),
+		Layout : parseLayout(c.StringSlice(flagName(FIELD_FLAG))),
+		FixedTrim : c.String(flagName(FIXED_TRIM_FLAG)),
 		HasHeader : c.Bool(flagName(HEADER_FLAG)),
 		Delimiter : c.String(flagName(DELIMITER_FLAG)),
 		Encoding : c.String(flagName(ENCODING_FLAG)),
+		Quote : c.String(flagName(QUOTE_FLAG)),
+		Comment : c.String(flagName(COMMENT_FLAG)),
+		LazyQuotes : c.Bool(flagName(LAZY_QUOTES_FLAG)),
+		TrimLeadingSpace : c.Bool(flagName(TRIM_LEADING_SPACE_FLAG)),
+		FieldsPerRecord : c.Int(flagName(FIELDS_PER_RECORD_FLAG)),
+		LineTerminator : c.String(flagName(LINE_TERMINATOR_FLAG)),
+
+		InferRows : c.Int(flagName(INFER_ROWS_FLAG)),
+		Formats : parseKeyValues(c.StringSlice(flagName(FORMAT_FLAG)), FORMAT_FLAG),
+		TimeZone : c.String(flagName(TIME_ZONE_FLAG)),
+
+		Script : c.String(flagName(SCRIPT_FLAG)),
+		Dialect : c.String(flagName(DIALECT_FLAG)),
+		InsertMethod : c.String(flagName(INSERT_METHOD_FLAG)),
+
+		KeyColumns : splitList(c.String(flagName(KEY_COLUMNS_FLAG))),
+		UpdateColumns : splitList(c.String(flagName(UPDATE_COLUMNS_FLAG))),
+		OnConflict : c.String(flagName(ON_CONFLICT_FLAG)),
+
+		SoftDeleteColumn : c.String(flagName(SOFT_DELETE_FLAG)),
+
+		RejectFile : c.String(flagName(REJECT_FILE_FLAG)),
+		MaxErrors : c.String(flagName(MAX_ERRORS_FLAG)),
+
+		Mapping : parseKeyValues(c.StringSlice(flagName(MAP_FLAG)), MAP_FLAG),
+		Constants : parseKeyValues(c.StringSlice(flagName(CONSTANT_FLAG)), CONSTANT_FLAG),
+		Transforms : parseKeyValues(c.StringSlice(flagName(TRANSFORM_FLAG)), TRANSFORM_FLAG),
+		Metadata : parseKeyValues(c.StringSlice(flagName(METADATA_FLAG)), METADATA_FLAG),
+
+		DryRun : c.Bool(flagName(DRY_RUN_FLAG)),
+		PreviewRows : c.Int(flagName(PREVIEW_ROWS_FLAG)),
+		Rollback : c.Bool(flagName(ROLLBACK_FLAG)),
+		Evolve : c.Bool(flagName(EVOLVE_FLAG)),
+		Widen : c.Bool(flagName(WIDEN_FLAG)),
+
+		PrimaryKey : splitList(c.String(flagName(PRIMARY_KEY_FLAG))),
+		Unique : c.StringSlice(flagName(UNIQUE_FLAG)),
+		Indexes : c.StringSlice(flagName(INDEX_FLAG)),
+		Identity : c.String(flagName(IDENTITY_FLAG)),
+		IndexAfterLoad : c.Bool(flagName(INDEX_AFTER_LOAD_FLAG)),
 	}
-	cliConfig.Validate()
 	return cliConfig
 }
 
+// Parse values like key=value given by repeated flag
+func parseKeyValues(values []string, flag string) map[string]string {
+	result := make(map[string]string)
+	for _, value := range values {
+		kv := strings.SplitN(value, "=", 2)
+		if len(kv) < 2 {
+			log.Fatalf("Value of %s should look like key=value: %s", flagName(flag), value)
+		}
+		result[kv[0]] = kv[1]
+	}
+	return result
+}
+
+// Parse fields of fixed width layout given by repeated flag
+func parseLayout(values []string) []FixedWidthField {
+	layout := make([]FixedWidthField, 0, len(values))
+	for _, value := range values {
+		field, err := ParseFixedWidthField(value)
+		if err != nil {
+			log.Fatalf("Bad value of %s: %v", flagName(FIELD_FLAG), err)
+		}
+		layout = append(layout, field)
+	}
+	return layout
+}
+
+// Parse comma separated list. Empty string means no values
+func splitList(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	values := strings.Split(value, ",")
+	for i, v := range values {
+		values[i] = strings.TrimSpace(v)
+	}
+	return values
+}
+
 func getPreset(c *cli.Context, configStorage ConfigStorage) Config {
 	presetName := c.String(flagName(PRESET_FLAG))
 	if presetName == "" {
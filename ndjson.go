@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"github.com/and-hom/csv2db/common"
+)
+
+// How nested objects of JSON input are loaded
+const JSON_NESTED_FLATTEN = "flatten"
+const JSON_NESTED_JSON = "json"
+
+var jsonNestedModes = []string{
+	JSON_NESTED_FLATTEN,
+	JSON_NESTED_JSON,
+}
+
+// Column names are collected from first objects. Keys missing there are rejected later.
+const NDJSON_SCAN_ROWS = 1000
+
+// Rows of newline delimited JSON objects. The first row is made of keys like CSV header.
+// Nested objects are flattened into parent.child columns or kept as JSON, arrays are kept as JSON,
+// null is empty value. Empty lines are skipped.
+type ndjsonReader struct {
+	input      *bufio.Reader
+	flatten    bool
+	separator  string
+	columns    []string
+	indexes    map[string]int
+	// parsed objects of column names scan
+	buffered   []ndjsonRow
+	colDefs    []common.ColDef
+	line       int
+	headerRead bool
+	lastLine   int
+}
+
+type ndjsonRow struct {
+	fields []ndjsonField
+	line   int
+	err    error
+}
+
+type ndjsonField struct {
+	name  string
+	value string
+	null  bool
+	// value is object or array
+	json  bool
+}
+
+func newNdjsonReader(input io.Reader, nested, separator string) (*ndjsonReader, error) {
+	reader := &ndjsonReader{
+		input:bufio.NewReader(input),
+		flatten:nested != JSON_NESTED_JSON,
+		separator:separator,
+		indexes:make(map[string]int),
+	}
+	for len(reader.buffered) < NDJSON_SCAN_ROWS {
+		row, err := reader.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		for _, field := range row.fields {
+			if _, found := reader.indexes[field.name]; !found {
+				reader.indexes[field.name] = len(reader.columns)
+				reader.columns = append(reader.columns, field.name)
+			}
+		}
+		reader.buffered = append(reader.buffered, row)
+	}
+	reader.colDefs = reader.scannedColDefs()
+	return reader, nil
+}
+
+// Columns missing or null in some scanned objects are nullable. Columns of objects and arrays only are JSON,
+// type of other columns is not known.
+func (this *ndjsonReader) scannedColDefs() []common.ColDef {
+	rows := 0
+	values := make([]int, len(this.columns))
+	jsons := make([]int, len(this.columns))
+	for _, row := range this.buffered {
+		if row.err != nil {
+			continue
+		}
+		rows += 1
+		for _, field := range row.fields {
+			index := this.indexes[field.name]
+			if !field.null {
+				values[index] += 1
+			}
+			if field.json {
+				jsons[index] += 1
+			}
+		}
+	}
+	colDefs := make([]common.ColDef, len(this.columns))
+	for i := range this.columns {
+		colDefs[i].OrderIndex = i
+		colDefs[i].Nullable = values[i] < rows
+		if jsons[i] > 0 && jsons[i] == values[i] {
+			colDefs[i].GoType = common.Json
+		}
+	}
+	return colDefs
+}
+
+// Next not empty line parsed. Broken JSON is returned as row error.
+func (this *ndjsonReader) next() (ndjsonRow, error) {
+	for {
+		content, err := this.input.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return ndjsonRow{}, err
+		}
+		if len(content) == 0 && err == io.EOF {
+			return ndjsonRow{}, io.EOF
+		}
+		this.line += 1
+		content = bytes.TrimSpace(content)
+		if len(content) == 0 {
+			continue
+		}
+		row := ndjsonRow{line:this.line}
+		row.fields, row.err = this.parseObject(content, "")
+		return row, nil
+	}
+}
+
+func (this *ndjsonReader) parseObject(content []byte, prefix string) ([]ndjsonField, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	if token, err := decoder.Token(); err != nil {
+		return nil, err
+	} else if token != json.Delim('{') {
+		return nil, errors.New("JSON object expected")
+	}
+	fields := make([]ndjsonField, 0)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		name := prefix + token.(string)
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, err
+		}
+		if raw[0] == '{' && this.flatten {
+			nested, err := this.parseObject(raw, name + this.separator)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, nested...)
+			continue
+		}
+		value, err := jsonValue(raw)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, ndjsonField{name:name, value:value, null:raw[0] == 'n', json:raw[0] == '{' || raw[0] == '['})
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("Line should contain one JSON object")
+	}
+	return fields, nil
+}
+
+// Strings are unquoted, null is empty, objects and arrays are compacted JSON
+func jsonValue(raw json.RawMessage) (string, error) {
+	switch raw[0] {
+	case '"':
+		value := ""
+		err := json.Unmarshal(raw, &value)
+		return value, err
+	case 'n':
+		return "", nil
+	case '{', '[':
+		compacted := bytes.Buffer{}
+		err := json.Compact(&compacted, raw)
+		return compacted.String(), err
+	default:
+		// numbers and booleans
+		return string(raw), nil
+	}
+}
+
+func (this *ndjsonReader) Read() ([]string, error) {
+	if !this.headerRead {
+		this.headerRead = true
+		if len(this.columns) == 0 {
+			return nil, io.EOF
+		}
+		this.lastLine = 1
+		if len(this.buffered) > 0 {
+			this.lastLine = this.buffered[0].line
+		}
+		return append([]string{}, this.columns...), nil
+	}
+	var row ndjsonRow
+	if len(this.buffered) > 0 {
+		row = this.buffered[0]
+		this.buffered = this.buffered[1:]
+	} else {
+		var err error
+		if row, err = this.next(); err != nil {
+			return nil, err
+		}
+	}
+	this.lastLine = row.line
+	if row.err != nil {
+		return nil, &csv.ParseError{StartLine:row.line, Line:row.line, Err:row.err}
+	}
+	line := make([]string, len(this.columns))
+	for _, field := range row.fields {
+		index, found := this.indexes[field.name]
+		if !found {
+			return nil, &csv.ParseError{StartLine:row.line, Line:row.line,
+				Err:fmt.Errorf("Key %s is missing in first %d objects", field.name, NDJSON_SCAN_ROWS)}
+		}
+		line[index] = field.value
+	}
+	return line, nil
+}
+
+// Types of columns known by scanned objects
+func (this *ndjsonReader) ColumnTypes() []common.ColDef {
+	return this.colDefs
+}
+
+func (this *ndjsonReader) FieldPos(field int) (int, int) {
+	return this.lastLine, field + 1
+}
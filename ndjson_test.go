@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"strings"
+	"encoding/csv"
+	"io"
+	"reflect"
+	"github.com/stretchr/testify/assert"
+	"github.com/and-hom/csv2db/common"
+)
+
+func TestNdjsonReader(t *testing.T) {
+	input := `{"id": 1, "user": {"name": "ann", "geo": {"city": "Oslo"}}, "tags": ["a", "b"]}
+
+{"id": 2, "note": null, "ok": true}
+{"id": 3,
+`
+	reader, err := newNdjsonReader(strings.NewReader(input), JSON_NESTED_FLATTEN, "_")
+	assert.Nil(t, err)
+	line, _ := reader.Read()
+	assert.Equal(t, []string{"id", "user_name", "user_geo_city", "tags", "note", "ok"}, line)
+	line, _ = reader.Read()
+	assert.Equal(t, []string{"1", "ann", "Oslo", `["a","b"]`, "", ""}, line)
+	line, _ = reader.Read()
+	assert.Equal(t, []string{"2", "", "", "", "", "true"}, line)
+	lineNumber, _ := reader.FieldPos(0)
+	assert.Equal(t, 3, lineNumber)
+	_, err = reader.Read()
+	_, isParseError := err.(*csv.ParseError)
+	assert.True(t, isParseError)
+	_, err = reader.Read()
+	assert.Equal(t, io.EOF, err)
+
+	reader, err = newNdjsonReader(strings.NewReader(input), JSON_NESTED_JSON, "")
+	assert.Nil(t, err)
+	reader.Read()
+	line, _ = reader.Read()
+	assert.Equal(t, `{"name":"ann","geo":{"city":"Oslo"}}`, line[1])
+}
+
+func TestNdjsonColumnTypes(t *testing.T) {
+	input := `{"id": 1, "user": {"name": "ann"}, "tags": ["a"], "note": "x"}
+{"id": 2, "user": null, "tags": [], "note": null}
+{"id": 3, "tags": "b"}
+`
+	reader, err := newNdjsonReader(strings.NewReader(input), JSON_NESTED_JSON, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []common.ColDef{
+		{GoType:reflect.Invalid, OrderIndex:0},
+		{GoType:common.Json, OrderIndex:1, Nullable:true},
+		{GoType:reflect.Invalid, OrderIndex:2},
+		{GoType:reflect.Invalid, OrderIndex:3, Nullable:true},
+	}, reader.ColumnTypes())
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"time"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/deprecated"
+	"github.com/parquet-go/parquet-go/format"
+	"github.com/and-hom/csv2db/common"
+)
+
+// Rows read from row group at once
+const PARQUET_BATCH_ROWS = 256
+
+// Julian day of 1970-01-01 used by legacy INT96 timestamps
+const JULIAN_UNIX_EPOCH = 2440588
+
+// Rows of Parquet file read row group by row group. The first row is made of top level field names like CSV header.
+// Values are formatted to be parsed by value mappers of column types, null is empty value.
+// Lists, maps and groups are loaded as JSON.
+type parquetReader struct {
+	file       *parquet.File
+	// closed together with reader
+	input      io.Closer
+	columns    []parquetColumn
+	// some fields are not leaf columns and the whole row should be reconstructed
+	nested     bool
+	rowGroup   int
+	rows       parquet.Rows
+	batch      []parquet.Row
+	batchSize  int
+	batchIndex int
+	// row number in file starting from 1
+	rowNumber  int
+	headerRead bool
+}
+
+type parquetColumn struct {
+	name   string
+	colDef common.ColDef
+	// column index of leaf field or -1 for JSON field
+	index  int
+	node   parquet.Node
+}
+
+func newParquetReader(input io.ReaderAt, size int64, closer io.Closer) (*parquetReader, error) {
+	file, err := parquet.OpenFile(input, size)
+	if err != nil {
+		return nil, err
+	}
+	reader := &parquetReader{file:file, input:closer, batch:make([]parquet.Row, PARQUET_BATCH_ROWS)}
+	for i, field := range file.Schema().Fields() {
+		column := parquetColumn{name:field.Name(), index:-1, node:field}
+		if field.Leaf() && !field.Repeated() {
+			leaf, _ := file.Schema().Lookup(field.Name())
+			column.index = leaf.ColumnIndex
+			column.colDef = parquetColDef(field.Type())
+		} else {
+			reader.nested = true
+			column.colDef = common.ColDef{GoType:common.Json}
+		}
+		column.colDef.OrderIndex = i
+		column.colDef.Nullable = field.Optional()
+		reader.columns = append(reader.columns, column)
+	}
+	return reader, nil
+}
+
+// Column type of leaf field by logical type or by physical type if logical one is missing
+func parquetColDef(fieldType parquet.Type) common.ColDef {
+	logicalType := fieldType.LogicalType()
+	if logicalType != nil {
+		switch logical := logicalType.Value.(type) {
+		case *format.DecimalType:
+			return common.ColDef{GoType:common.Decimal, Precision:int(logical.Precision), Scale:int(logical.Scale)}
+		case *format.DateType:
+			return common.ColDef{GoType:common.Date}
+		case *format.TimeType:
+			return common.ColDef{GoType:common.Time}
+		case *format.TimestampType:
+			if logical.IsAdjustedToUTC {
+				return common.ColDef{GoType:common.TimestampTz}
+			}
+			return common.ColDef{GoType:common.Timestamp}
+		case *format.IntType:
+			switch {
+			case logical.IsSigned && logical.BitWidth <= 32:
+				return common.ColDef{GoType:reflect.Int32}
+			case logical.BitWidth <= 32:
+				return common.ColDef{GoType:reflect.Int64}
+			case !logical.IsSigned:
+				// unsigned 64 bit values may not fit bigint
+				return common.ColDef{GoType:common.Decimal, Precision:20}
+			}
+		case *format.JsonType:
+			return common.ColDef{GoType:common.Json}
+		}
+	}
+	switch fieldType.Kind() {
+	case parquet.Boolean:
+		return common.ColDef{GoType:reflect.Bool}
+	case parquet.Int32:
+		return common.ColDef{GoType:reflect.Int32}
+	case parquet.Int64:
+		return common.ColDef{GoType:reflect.Int64}
+	case parquet.Int96:
+		return common.ColDef{GoType:common.Timestamp}
+	case parquet.Float, parquet.Double:
+		// float is not supported by all databases
+		return common.ColDef{GoType:reflect.Float64}
+	default:
+		return common.ColDef{GoType:reflect.String}
+	}
+}
+
+// Types of columns by order index
+func (this *parquetReader) ColumnTypes() []common.ColDef {
+	colDefs := make([]common.ColDef, len(this.columns))
+	for i, column := range this.columns {
+		colDefs[i] = column.colDef
+	}
+	return colDefs
+}
+
+func (this *parquetReader) Read() ([]string, error) {
+	if !this.headerRead {
+		this.headerRead = true
+		header := make([]string, len(this.columns))
+		for i, column := range this.columns {
+			header[i] = column.name
+		}
+		return header, nil
+	}
+	row, err := this.next()
+	if err != nil {
+		return nil, err
+	}
+	this.rowNumber += 1
+
+	values := make(map[int]parquet.Value, len(row))
+	for _, value := range row {
+		if _, found := values[value.Column()]; !found {
+			values[value.Column()] = value
+		}
+	}
+	var reconstructed map[string]interface{}
+	if this.nested {
+		reconstructed = make(map[string]interface{})
+		if err := this.file.Schema().Reconstruct(&reconstructed, row); err != nil {
+			return nil, fmt.Errorf("Can not read row %d: %v", this.rowNumber, err)
+		}
+	}
+	line := make([]string, len(this.columns))
+	for i, column := range this.columns {
+		if column.index < 0 {
+			line[i], err = parquetJson(reconstructed[column.name])
+		} else {
+			line[i], err = formatParquetValue(values[column.index], column.node.Type())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Can not read field %s of row %d: %v", column.name, this.rowNumber, err)
+		}
+	}
+	return line, nil
+}
+
+// Next row of current row group. Row groups are opened one by one.
+func (this *parquetReader) next() (parquet.Row, error) {
+	for this.batchIndex >= this.batchSize {
+		if this.rows == nil {
+			if this.rowGroup >= len(this.file.RowGroups()) {
+				return nil, io.EOF
+			}
+			this.rows = this.file.RowGroups()[this.rowGroup].Rows()
+			this.rowGroup += 1
+		}
+		n, err := this.rows.ReadRows(this.batch)
+		this.batchSize, this.batchIndex = n, 0
+		if err == io.EOF {
+			this.rows.Close()
+			this.rows = nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	this.batchIndex += 1
+	return this.batch[this.batchIndex - 1], nil
+}
+
+func (this *parquetReader) FieldPos(field int) (int, int) {
+	return this.rowNumber, field + 1
+}
+
+func (this *parquetReader) Close() error {
+	if this.rows != nil {
+		this.rows.Close()
+	}
+	return this.input.Close()
+}
+
+// Null is empty value
+func parquetJson(value interface{}) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+	bytes, err := json.Marshal(value)
+	return string(bytes), err
+}
+
+func formatParquetValue(value parquet.Value, fieldType parquet.Type) (string, error) {
+	if value.IsNull() {
+		return "", nil
+	}
+	var logical format.LogicalTypeValue
+	if fieldType.LogicalType() != nil {
+		logical = fieldType.LogicalType().Value
+	}
+	switch logical := logical.(type) {
+	case *format.DecimalType:
+		return formatDecimal(parquetUnscaled(value), int(logical.Scale)), nil
+	case *format.DateType:
+		return time.Unix(int64(value.Int32()) * 24 * 60 * 60, 0).UTC().Format(common.DATE_LAYOUT), nil
+	case *format.TimeType:
+		var sinceMidnight time.Duration
+		if value.Kind() == parquet.Int32 {
+			sinceMidnight = time.Duration(value.Int32()) * logical.Unit.Value.Duration()
+		} else {
+			sinceMidnight = time.Duration(value.Int64()) * logical.Unit.Value.Duration()
+		}
+		return time.Time{}.Add(sinceMidnight).Format(common.TIME_LAYOUT), nil
+	case *format.TimestampType:
+		t := parquetTime(value.Int64(), logical.Unit.Value.Duration())
+		if logical.IsAdjustedToUTC {
+			return t.Format(time.RFC3339Nano), nil
+		}
+		return t.Format("2006-01-02 15:04:05.999999999"), nil
+	case *format.IntType:
+		if !logical.IsSigned && value.Kind() == parquet.Int32 {
+			return strconv.FormatUint(uint64(uint32(value.Int32())), 10), nil
+		} else if !logical.IsSigned {
+			return strconv.FormatUint(uint64(value.Int64()), 10), nil
+		}
+	case *format.UUIDType:
+		b := value.ByteArray()
+		if len(b) == 16 {
+			return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+		}
+	}
+	switch value.Kind() {
+	case parquet.Boolean:
+		return strconv.FormatBool(value.Boolean()), nil
+	case parquet.Int32:
+		return strconv.FormatInt(int64(value.Int32()), 10), nil
+	case parquet.Int64:
+		return strconv.FormatInt(value.Int64(), 10), nil
+	case parquet.Int96:
+		return parquetInt96Time(value.Int96()).Format("2006-01-02 15:04:05.999999999"), nil
+	case parquet.Float:
+		return strconv.FormatFloat(float64(value.Float()), 'g', -1, 32), nil
+	case parquet.Double:
+		return strconv.FormatFloat(value.Double(), 'g', -1, 64), nil
+	default:
+		// strings written without logical type are usual
+		return string(value.ByteArray()), nil
+	}
+}
+
+// Time by count of units since epoch in UTC
+func parquetTime(count int64, unit time.Duration) time.Time {
+	switch unit {
+	case time.Millisecond:
+		return time.UnixMilli(count).UTC()
+	case time.Microsecond:
+		return time.UnixMicro(count).UTC()
+	default:
+		return time.Unix(0, count).UTC()
+	}
+}
+
+// Legacy timestamp: nanoseconds of day and julian day
+func parquetInt96Time(value deprecated.Int96) time.Time {
+	nanos := int64(uint64(value[1]) << 32 | uint64(value[0]))
+	days := int64(value[2]) - JULIAN_UNIX_EPOCH
+	return time.Unix(days * 24 * 60 * 60, nanos).UTC()
+}
+
+// Unscaled decimal value is integer or big-endian two's complement bytes
+func parquetUnscaled(value parquet.Value) *big.Int {
+	switch value.Kind() {
+	case parquet.Int32:
+		return big.NewInt(int64(value.Int32()))
+	case parquet.Int64:
+		return big.NewInt(value.Int64())
+	}
+	b := value.ByteArray()
+	unscaled := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0] & 0x80 != 0 {
+		unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(b) * 8)))
+	}
+	return unscaled
+}
+
+func formatDecimal(unscaled *big.Int, scale int) string {
+	digits := new(big.Int).Abs(unscaled).String()
+	sign := ""
+	if unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	if scale <= 0 {
+		return sign + digits
+	}
+	for len(digits) <= scale {
+		digits = "0" + digits
+	}
+	return sign + digits[:len(digits) - scale] + "." + digits[len(digits) - scale:]
+}
+
+// Parquet file is read by offsets. Bytes read are counted for progress bar.
+type countingReaderAt struct {
+	io.ReaderAt
+	count *int64
+}
+
+func (this countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := this.ReaderAt.ReadAt(p, off)
+	atomic.AddInt64(this.count, int64(n))
+	return n, err
+}
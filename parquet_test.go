@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"bytes"
+	"io"
+	"math/big"
+	"reflect"
+	"time"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/and-hom/csv2db/common"
+)
+
+type parquetTestRow struct {
+	Id     int64     `parquet:"id"`
+	Name   *string   `parquet:"name,optional"`
+	Amount int64     `parquet:"amount,decimal(2:12)"`
+	Day    int32     `parquet:"day,date"`
+	Ts     time.Time `parquet:"ts,timestamp(millisecond)"`
+	Tags   []string  `parquet:"tags,list"`
+}
+
+func TestParquetReader(t *testing.T) {
+	name := "ann"
+	rows := []parquetTestRow{
+		{Id:1, Name:&name, Amount:12345, Day:19000, Ts:time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Tags:[]string{"a"}},
+		{Id:2, Amount:-5, Day:19001, Ts:time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+	}
+	buffer := bytes.Buffer{}
+	assert.Nil(t, parquet.Write(&buffer, rows, parquet.MaxRowsPerRowGroup(1)))
+
+	reader, err := newParquetReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()), io.NopCloser(nil))
+	assert.Nil(t, err)
+	assert.Equal(t, []common.ColDef{
+		{GoType:reflect.Int64, OrderIndex:0},
+		{GoType:reflect.String, OrderIndex:1, Nullable:true},
+		{GoType:common.Decimal, OrderIndex:2, Precision:12, Scale:2},
+		{GoType:common.Date, OrderIndex:3},
+		{GoType:common.TimestampTz, OrderIndex:4},
+		{GoType:common.Json, OrderIndex:5},
+	}, reader.ColumnTypes())
+	line, _ := reader.Read()
+	assert.Equal(t, []string{"id", "name", "amount", "day", "ts", "tags"}, line)
+	line, _ = reader.Read()
+	assert.Equal(t, []string{"1", "ann", "123.45", "2022-01-08", "2024-01-02T03:04:05Z", `["a"]`}, line)
+	line, _ = reader.Read()
+	assert.Equal(t, []string{"2", "", "-0.05", "2022-01-09", "2024-01-03T00:00:00Z", "[]"}, line)
+	_, err = reader.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestFormatDecimal(t *testing.T) {
+	assert.Equal(t, "0.05", formatDecimal(big.NewInt(5), 2))
+	assert.Equal(t, "-12.345", formatDecimal(big.NewInt(-12345), 3))
+	assert.Equal(t, "700", formatDecimal(big.NewInt(700), 0))
+}
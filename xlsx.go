@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"github.com/xuri/excelize/v2"
+	"github.com/and-hom/csv2db/common"
+)
+
+// Built-in number formats of dates and times
+var xlsxDateFormats = map[int]bool{
+	14:true, 15:true, 16:true, 17:true, 18:true, 19:true, 20:true, 21:true, 22:true,
+	27:true, 28:true, 29:true, 30:true, 31:true, 32:true, 33:true, 34:true, 35:true, 36:true,
+	45:true, 46:true, 47:true, 50:true, 51:true, 52:true, 53:true, 54:true, 55:true, 56:true, 57:true, 58:true,
+}
+
+// Quoted text, escaped chars and [color] or [$-locale] sections of custom number format
+var xlsxFormatLiterals = regexp.MustCompile(`"[^"]*"|\\.|\[[^\]]*\]`)
+
+var xlsxCellPattern = regexp.MustCompile(`^([A-Za-z]+)([0-9]*)$`)
+
+// Rectangle of sheet. Zero last row means all rows. Zero range means used area of sheet.
+type CellRange struct {
+	FirstColumn int
+	FirstRow    int
+	LastColumn  int
+	LastRow     int
+}
+
+// Parse range like B2:F100. Row may be omitted: B:F means all rows of columns B-F, B3:F means rows from 3rd.
+func ParseCellRange(value string) (CellRange, error) {
+	if value == "" {
+		return CellRange{}, nil
+	}
+	bounds := strings.Split(value, ":")
+	if len(bounds) != 2 {
+		return CellRange{}, fmt.Errorf("Cell range should look like A1:D20: %s", value)
+	}
+	firstColumn, firstRow, err := parseCell(bounds[0])
+	if err != nil {
+		return CellRange{}, err
+	}
+	lastColumn, lastRow, err := parseCell(bounds[1])
+	if err != nil {
+		return CellRange{}, err
+	}
+	if firstRow == 0 {
+		firstRow = 1
+	}
+	if lastColumn < firstColumn || lastRow != 0 && lastRow < firstRow {
+		return CellRange{}, fmt.Errorf("Last cell of range %s is before the first one", value)
+	}
+	return CellRange{FirstColumn:firstColumn, FirstRow:firstRow, LastColumn:lastColumn, LastRow:lastRow}, nil
+}
+
+// Column number and row number or 0 if row is missing
+func parseCell(cell string) (int, int, error) {
+	parts := xlsxCellPattern.FindStringSubmatch(cell)
+	if parts == nil {
+		return 0, 0, fmt.Errorf("Bad cell %s", cell)
+	}
+	column, err := excelize.ColumnNameToNumber(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	row := 0
+	if parts[2] != "" {
+		if row, err = strconv.Atoi(parts[2]); err != nil || row == 0 {
+			return 0, 0, fmt.Errorf("Bad row of cell %s", cell)
+		}
+	}
+	return column, row, nil
+}
+
+// Rows of one sheet. Numbers are read without formatting, dates and times are converted to ISO layouts,
+// booleans to true and false. Empty rows are skipped like empty CSV lines.
+type xlsxReader struct {
+	rows       [][]string
+	rowNumbers []int
+	index      int
+}
+
+// Sheet is selected by name or by 1-based index. The first sheet is used by default.
+func newXlsxReader(input io.Reader, sheet string, cellRange CellRange) (*xlsxReader, error) {
+	file, err := excelize.OpenReader(input)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sheetName, err := findSheet(file, sheet)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := file.GetRows(sheetName, excelize.Options{RawCellValue:true})
+	if err != nil {
+		return nil, err
+	}
+	date1904 := false
+	if props, err := file.GetWorkbookProps(); err == nil && props.Date1904 != nil {
+		date1904 = *props.Date1904
+	}
+	converter := xlsxConverter{file:file, sheet:sheetName, date1904:date1904, dateStyles:make(map[int]bool)}
+
+	if cellRange.FirstColumn == 0 {
+		cellRange = usedArea(rows)
+	}
+	width := max(cellRange.LastColumn - cellRange.FirstColumn + 1, 0)
+	reader := &xlsxReader{}
+	for i := cellRange.FirstRow - 1; i < len(rows) && (cellRange.LastRow == 0 || i < cellRange.LastRow); i++ {
+		line := make([]string, width)
+		empty := true
+		for j := range line {
+			column := cellRange.FirstColumn + j
+			if column > len(rows[i]) || rows[i][column - 1] == "" {
+				continue
+			}
+			if line[j], err = converter.convert(column, i + 1, rows[i][column - 1]); err != nil {
+				return nil, err
+			}
+			empty = false
+		}
+		if !empty {
+			reader.rows = append(reader.rows, line)
+			reader.rowNumbers = append(reader.rowNumbers, i + 1)
+		}
+	}
+	return reader, nil
+}
+
+// Columns from the first to the last not empty one
+func usedArea(rows [][]string) CellRange {
+	area := CellRange{FirstRow:1}
+	for _, row := range rows {
+		for column, value := range row {
+			if value == "" {
+				continue
+			}
+			if area.FirstColumn == 0 || column + 1 < area.FirstColumn {
+				area.FirstColumn = column + 1
+			}
+			area.LastColumn = max(area.LastColumn, column + 1)
+		}
+	}
+	return area
+}
+
+func findSheet(file *excelize.File, sheet string) (string, error) {
+	sheets := file.GetSheetList()
+	if sheet == "" {
+		return sheets[0], nil
+	}
+	for _, name := range sheets {
+		if name == sheet {
+			return name, nil
+		}
+	}
+	if index, err := strconv.Atoi(sheet); err == nil && index > 0 && index <= len(sheets) {
+		return sheets[index - 1], nil
+	}
+	return "", fmt.Errorf("Sheet %s not found. Available are: %s", sheet, strings.Join(sheets, ", "))
+}
+
+func (this *xlsxReader) Read() ([]string, error) {
+	if this.index >= len(this.rows) {
+		return nil, io.EOF
+	}
+	this.index += 1
+	return this.rows[this.index - 1], nil
+}
+
+// Sheet row and column of field of the last row
+func (this *xlsxReader) FieldPos(field int) (int, int) {
+	return this.rowNumbers[this.index - 1], field + 1
+}
+
+type xlsxConverter struct {
+	file       *excelize.File
+	sheet      string
+	date1904   bool
+	// style id -> number format is date or time
+	dateStyles map[int]bool
+}
+
+func (this xlsxConverter) convert(column, row int, raw string) (string, error) {
+	cell, err := excelize.CoordinatesToCellName(column, row)
+	if err != nil {
+		return "", err
+	}
+	cellType, err := this.file.GetCellType(this.sheet, cell)
+	if err != nil {
+		return "", err
+	}
+	switch cellType {
+	case excelize.CellTypeBool:
+		return strconv.FormatBool(raw == "1"), nil
+	case excelize.CellTypeNumber, excelize.CellTypeUnset:
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return raw, nil
+		}
+		isDate, err := this.isDate(cell)
+		if err != nil {
+			return "", err
+		}
+		if isDate {
+			return this.formatDate(number)
+		}
+		return strconv.FormatFloat(number, 'f', -1, 64), nil
+	default:
+		return raw, nil
+	}
+}
+
+func (this xlsxConverter) isDate(cell string) (bool, error) {
+	styleId, err := this.file.GetCellStyle(this.sheet, cell)
+	if err != nil {
+		return false, err
+	}
+	if isDate, found := this.dateStyles[styleId]; found {
+		return isDate, nil
+	}
+	style, err := this.file.GetStyle(styleId)
+	if err != nil {
+		return false, err
+	}
+	isDate := xlsxDateFormats[style.NumFmt]
+	if style.CustomNumFmt != nil {
+		format := strings.ToLower(xlsxFormatLiterals.ReplaceAllString(*style.CustomNumFmt, ""))
+		isDate = strings.ContainsAny(format, "ymdhs")
+	}
+	this.dateStyles[styleId] = isDate
+	return isDate, nil
+}
+
+// Days without fraction are dates, fractions of day are times
+func (this xlsxConverter) formatDate(number float64) (string, error) {
+	t, err := excelize.ExcelDateToTime(number, this.date1904)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case number < 1:
+		return t.Format("15:04:05"), nil
+	case number == math.Trunc(number):
+		return t.Format(common.DATE_LAYOUT), nil
+	default:
+		return t.Format("2006-01-02 15:04:05"), nil
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+	"github.com/xuri/excelize/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCellRange(t *testing.T) {
+	cellRange, err := ParseCellRange("B2:F100")
+	assert.Nil(t, err)
+	assert.Equal(t, CellRange{FirstColumn:2, FirstRow:2, LastColumn:6, LastRow:100}, cellRange)
+
+	cellRange, err = ParseCellRange("AA:AC")
+	assert.Nil(t, err)
+	assert.Equal(t, CellRange{FirstColumn:27, FirstRow:1, LastColumn:29}, cellRange)
+
+	_, err = ParseCellRange("F1:B2")
+	assert.Error(t, err)
+	_, err = ParseCellRange("B2")
+	assert.Error(t, err)
+}
+
+func TestXlsxReader(t *testing.T) {
+	file := excelize.NewFile()
+	file.NewSheet("Data")
+	file.SetSheetRow("Data", "B2", &[]interface{}{"name", "price", "day", "flag"})
+	file.SetSheetRow("Data", "B4", &[]interface{}{"apple", 1234.5, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), true})
+	money := "#,##0.00 \"rub\""
+	moneyStyle, _ := file.NewStyle(&excelize.Style{CustomNumFmt:&money})
+	dateStyle, _ := file.NewStyle(&excelize.Style{NumFmt:14})
+	file.SetCellStyle("Data", "C4", "C4", moneyStyle)
+	file.SetCellStyle("Data", "D4", "D4", dateStyle)
+	buffer, err := file.WriteToBuffer()
+	assert.Nil(t, err)
+
+	reader, err := newXlsxReader(buffer, "2", CellRange{})
+	assert.Nil(t, err)
+	line, _ := reader.Read()
+	assert.Equal(t, []string{"name", "price", "day", "flag"}, line)
+	line, _ = reader.Read()
+	assert.Equal(t, []string{"apple", "1234.5", "2024-01-02", "true"}, line)
+	row, _ := reader.FieldPos(0)
+	assert.Equal(t, 4, row)
+	_, err = reader.Read()
+	assert.Error(t, err)
+}